@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefix for each organization's attribute access policy
+const accessPolicyPrefix = "accesspolicy"
+
+// Fabric CA puts the identity type in every certificate it issues; admins manage their org's policy.
+// Networks whose crypto material cryptogen generated issue no attributes, their admins carry the
+// admin node OU instead, which Fabric CA admins also have when the MSP enables node OUs.
+const (
+	identityTypeAttribute = "hf.Type"
+	identityTypeAdmin     = "admin"
+	adminOU               = "admin"
+)
+
+// Reports whether the caller is an admin of its organization, by either its Fabric CA identity
+// type or its node OU
+func callerIsAdmin(ctx contractapi.TransactionContextInterface) (bool, error) {
+	if ctx.GetClientIdentity().AssertAttributeValue(identityTypeAttribute, identityTypeAdmin) == nil {
+		return true, nil
+	}
+
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	return cert != nil && contains(cert.Subject.OrganizationalUnit, adminOU), nil
+}
+
+// Transactions that must stay reachable whatever the rules say, or an organization could lock
+// itself out of its own policy or be unable to record a denial
+var unguardedTransactions = map[string]bool{
+	"SetAccessPolicy":    true,
+	"GetAccessPolicy":    true,
+	"CheckAccess":        true,
+	"RecordAccessDenial": true,
+}
+
+// Replaces the attribute access policy of the caller's organization.
+// Only admins of that organization may do this.
+func SetAccessPolicy(ctx contractapi.TransactionContextInterface, rulesJSON string) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	admin, err := callerIsAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("only admins of %s can change its access policy", mspID)
+	}
+
+	var rules map[string][]models.AttributeRule
+	if err := json.Unmarshal([]byte(rulesJSON), &rules); err != nil {
+		return fmt.Errorf("invalid access rules: %v", err)
+	}
+	for transaction, attributes := range rules {
+		if unguardedTransactions[transaction] {
+			return fmt.Errorf("transaction %s cannot be restricted", transaction)
+		}
+		for _, rule := range attributes {
+			if rule.Attribute == "" || rule.Value == "" {
+				return fmt.Errorf("rules for %s need both an attribute and a value", transaction)
+			}
+		}
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	accessPolicy := models.AccessPolicy{
+		MSPID:     mspID,
+		Rules:     rules,
+		UpdatedBy: clientID,
+		Timestamp: txTime.Format(time.RFC3339),
+	}
+
+	// Map keys are marshalled in sorted order, so every peer writes the same bytes
+	policyJSON, err := json.Marshal(accessPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access policy: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(accessPolicyPrefix, []string{mspID})
+	if err != nil {
+		return fmt.Errorf("failed to create access policy key: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(key, policyJSON); err != nil {
+		return fmt.Errorf("failed to save access policy: %v", err)
+	}
+
+	return emitLedgerEvent(ctx, models.EventAccessPolicyChanged, mspID, "")
+}
+
+// Returns an organization's access policy as JSON, with no rules if it never set one
+func GetAccessPolicy(ctx contractapi.TransactionContextInterface, mspID string) (string, error) {
+	accessPolicy, err := readAccessPolicy(ctx, mspID)
+	if err != nil {
+		return "", err
+	}
+
+	policyJSON, err := json.Marshal(accessPolicy)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal access policy: %v", err)
+	}
+
+	return string(policyJSON), nil
+}
+
+func readAccessPolicy(ctx contractapi.TransactionContextInterface, mspID string) (*models.AccessPolicy, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(accessPolicyPrefix, []string{mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access policy key: %v", err)
+	}
+
+	policyJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access policy: %v", err)
+	}
+	if policyJSON == nil {
+		return &models.AccessPolicy{MSPID: mspID, Rules: map[string][]models.AttributeRule{}}, nil
+	}
+
+	var accessPolicy models.AccessPolicy
+	if err := json.Unmarshal(policyJSON, &accessPolicy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal access policy: %v", err)
+	}
+
+	return &accessPolicy, nil
+}
+
+// Returns the first rule of the caller's organization that the caller fails for the transaction, or nil
+func failedAccessRule(ctx contractapi.TransactionContextInterface, transaction string) (*models.AttributeRule, error) {
+	if unguardedTransactions[transaction] {
+		return nil, nil
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	accessPolicy, err := readAccessPolicy(ctx, mspID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range accessPolicy.Rules[transaction] {
+		if ctx.GetClientIdentity().AssertAttributeValue(rule.Attribute, rule.Value) != nil {
+			return &rule, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Runs before every transaction and refuses callers that fail their organization's rules.
+// A refused transaction is never committed, so the denial is audited separately through RecordAccessDenial.
+func EnforceAccessPolicy(ctx contractapi.TransactionContextInterface) error {
+	transaction := transactionName(ctx)
+
+	rule, err := failedAccessRule(ctx, transaction)
+	if err != nil {
+		return err
+	}
+	if rule != nil {
+		return fmt.Errorf("access denied: %s requires %s=%s", transaction, rule.Attribute, rule.Value)
+	}
+
+	return nil
+}
+
+// Reports whether the caller passes their organization's rules for a transaction
+func CheckAccess(ctx contractapi.TransactionContextInterface, transaction string) (bool, error) {
+	rule, err := failedAccessRule(ctx, transaction)
+	if err != nil {
+		return false, err
+	}
+	return rule == nil, nil
+}
+
+// Transactions that may be refused before any file exists, so their denials are filed under no file
+var untargetedTransactions = map[string]bool{
+	"RegisterFile":   true,
+	"CreateWorkflow": true,
+}
+
+// Writes an audit entry for a transaction the caller was refused, filed under the file it targeted.
+// The rules are evaluated again here, so only genuine denials of the calling user can be recorded,
+// and only against files the caller can read: an entry moves the file's audit head, and any
+// concurrent update of that file would then fail its read-set check.
+func RecordAccessDenial(ctx contractapi.TransactionContextInterface, transaction string, targetID string) error {
+	rule, err := failedAccessRule(ctx, transaction)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return fmt.Errorf("the caller is allowed to run %s, there is no denial to record", transaction)
+	}
+
+	if targetID == "" {
+		if !untargetedTransactions[transaction] {
+			return fmt.Errorf("a denial of %s must name the file it targeted", transaction)
+		}
+	} else {
+		file, err := readFile(ctx, targetID)
+		if err != nil {
+			return err
+		}
+		if file == nil {
+			return fmt.Errorf("file does not exist: %s", targetID)
+		}
+		caller, err := callerReader(ctx)
+		if err != nil {
+			return err
+		}
+		if !caller.canRead(file) {
+			return fmt.Errorf("access denied: organization %s cannot read file %s", caller.mspID, targetID)
+		}
+	}
+
+	details := fmt.Sprintf("%s denied, caller lacks %s=%s", transaction, rule.Attribute, rule.Value)
+	if err := CreateAuditLog(ctx, targetID, "ACCESS_DENIED", details); err != nil {
+		return err
+	}
+
+	return emitLedgerEvent(ctx, models.EventAccessDenied, targetID, details)
+}
+
+// Returns the name of the invoked transaction, without any contract namespace
+func transactionName(ctx contractapi.TransactionContextInterface) string {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		function = function[i+1:]
+	}
+
+	// The contract API accepts a lower-case first letter and calls the exported function
+	runes := []rune(function)
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
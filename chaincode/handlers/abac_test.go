@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAccessPolicyRestrictsApprovals(t *testing.T) {
+	admin := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=admin::CN=ca.org2", attrs: map[string]string{"hf.Type": "admin"}}
+	approver := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=approver::CN=ca.org2", attrs: map[string]string{"hf.Type": "client", "role": "approver"}}
+	intern := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=intern::CN=ca.org2", attrs: map[string]string{"hf.Type": "client", "role": "intern"}}
+	org1User := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	start := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	setPolicy := func(ctx contractapi.TransactionContextInterface) error {
+		return SetAccessPolicy(ctx, `{"ApproveFile": [{"attribute": "role", "value": "approver"}]}`)
+	}
+
+	p := newPeer("peer0.org2")
+	if err := p.invoke("tx1", start, approver, setPolicy); err == nil {
+		t.Fatal("a non-admin changed the access policy")
+	}
+	p.endorse(t, "tx2", start, admin, setPolicy)
+
+	check := func(txID string, identity *fakeIdentity, transaction string) bool {
+		var allowed bool
+		p.endorse(t, txID, start, identity, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			allowed, err = CheckAccess(ctx, transaction)
+			return err
+		})
+		return allowed
+	}
+
+	if !check("tx3", approver, "ApproveFile") {
+		t.Error("approver is refused ApproveFile")
+	}
+	if check("tx4", intern, "ApproveFile") {
+		t.Error("intern is allowed ApproveFile")
+	}
+	if !check("tx5", intern, "RegisterFile") {
+		t.Error("intern is refused RegisterFile, which has no rules")
+	}
+	// Rules only bind the organization that set them
+	if !check("tx6", org1User, "ApproveFile") {
+		t.Error("Org1 user is bound by Org2's rules")
+	}
+
+	// Org2 approves file1 and so can read it; file2 is Org1's alone
+	p.endorse(t, "tx-reg1", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "report.pdf", "QmHash1", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+		return err
+	})
+	p.endorse(t, "tx-reg2", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file2", "secret.pdf", "QmHash2", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, "", `[{"mspId":"Org1MSP"}]`, "")
+		return err
+	})
+
+	// Denials are audited, but only genuine ones against files the caller can see
+	if err := p.invoke("tx7", start, approver, func(ctx contractapi.TransactionContextInterface) error {
+		return RecordAccessDenial(ctx, "ApproveFile", "file1")
+	}); err == nil {
+		t.Fatal("recorded a denial for an allowed caller")
+	}
+	for _, targetID := range []string{"", "missing", "file2"} {
+		if err := p.invoke("tx7-"+targetID, start, intern, func(ctx contractapi.TransactionContextInterface) error {
+			return RecordAccessDenial(ctx, "ApproveFile", targetID)
+		}); err == nil {
+			t.Errorf("recorded a denial of ApproveFile against %q", targetID)
+		}
+	}
+	p.endorse(t, "tx8", start, intern, func(ctx contractapi.TransactionContextInterface) error {
+		return RecordAccessDenial(ctx, "ApproveFile", "file1")
+	})
+
+	denials := 0
+	for key, value := range p.stub.State {
+		if strings.HasPrefix(key, "\x00audit\x00") && strings.Contains(key, "ACCESS_DENIED") {
+			denials++
+			if !strings.HasPrefix(key, "\x00audit\x00file1\x00") {
+				t.Errorf("denial filed under the wrong file: %q", key)
+			}
+			if !strings.Contains(string(value), "role=approver") || !strings.Contains(string(value), intern.id) {
+				t.Errorf("denial entry does not name the rule and user: %s", value)
+			}
+		}
+	}
+	if denials != 1 {
+		t.Fatalf("audited %d denials, want 1", denials)
+	}
+}
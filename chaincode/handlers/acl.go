@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The calling user, resolved once so query handlers can check many files
+type reader struct {
+	ctx   contractapi.TransactionContextInterface
+	mspID string
+}
+
+func callerReader(ctx contractapi.TransactionContextInterface) (*reader, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	return &reader{ctx: ctx, mspID: mspID}, nil
+}
+
+// Reports whether the caller may read the file. Files without readers predate read ACLs
+// and stay readable by everyone; the owning organization can always read its files, and
+// an organization offered the file can read it until it accepts or declines.
+// Organizations with a legal hold on the file keep reading it, whatever its readers.
+func (r *reader) canRead(file *models.File) bool {
+	if len(file.Readers) == 0 || file.OwnerMSP == r.mspID {
+		return true
+	}
+	if file.PendingTransfer != nil && file.PendingTransfer.ToMSP == r.mspID {
+		return true
+	}
+	for _, hold := range file.LegalHolds {
+		if hold.MSPID == r.mspID {
+			return true // Whoever holds a file can always read it
+		}
+	}
+	return r.matches(file.Readers)
+}
+
+// Reports whether any rule grants access to the caller
+func (r *reader) matches(rules []models.ReadRule) bool {
+	for _, rule := range rules {
+		if rule.MSPID != r.mspID {
+			continue
+		}
+		if rule.Attribute == "" || r.ctx.GetClientIdentity().AssertAttributeValue(rule.Attribute, rule.Value) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the caller may register new versions on top of the file: its owner or one
+// of its editors. Files registered before ownership records stay open to everyone.
+func canEdit(ctx contractapi.TransactionContextInterface, file *models.File) (bool, error) {
+	if file.OwnerMSP == "" {
+		return true, nil
+	}
+
+	owner, err := isOwner(ctx, file)
+	if err != nil || owner {
+		return owner, err
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return false, err
+	}
+	return caller.matches(file.Editors), nil
+}
+
+// Parses and validates a readers argument
+func parseReaders(readersJSON string) ([]models.ReadRule, error) {
+	return parseRules(readersJSON, "reader")
+}
+
+// Parses and validates a list of access rules, readers or editors
+func parseRules(rulesJSON string, kind string) ([]models.ReadRule, error) {
+	if rulesJSON == "" {
+		return nil, nil
+	}
+
+	var rules []models.ReadRule
+	if err := json.Unmarshal([]byte(rulesJSON), &rules); err != nil {
+		return nil, fmt.Errorf("invalid %ss: %v", kind, err)
+	}
+	for _, rule := range rules {
+		if rule.MSPID == "" {
+			return nil, fmt.Errorf("every %s rule must name an MSP ID", kind)
+		}
+		if rule.Value != "" && rule.Attribute == "" {
+			return nil, fmt.Errorf("%s rule for %s has a value but no attribute", kind, rule.MSPID)
+		}
+	}
+
+	return rules, nil
+}
+
+// Adds organization-wide read access for each org unless it already has it
+func withOrgReaders(readers []models.ReadRule, orgs ...string) []models.ReadRule {
+	for _, org := range orgs {
+		found := false
+		for _, rule := range readers {
+			if rule.MSPID == org && rule.Attribute == "" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			readers = append(readers, models.ReadRule{MSPID: org})
+		}
+	}
+	return readers
+}
+
+// Reports whether the caller may read a file, for clients that gate access outside the ledger
+func CanReadFile(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if file == nil {
+		return false, fmt.Errorf("file does not exist: %s", id)
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return caller.canRead(file), nil
+}
+
+// Replaces the readers of a file. Only the owning organization may change them, and the
+// organizations that approve the file, in any workflow stage, always keep read access.
+func UpdateReaders(ctx contractapi.TransactionContextInterface, id string, readersJSON string) error {
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("file does not exist: %s", id)
+	}
+	if err := requireLive(file); err != nil {
+		return err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if file.OwnerMSP == "" {
+		return fmt.Errorf("file %s predates read ACLs and has no owning organization", id)
+	}
+	if file.OwnerMSP != mspID {
+		return fmt.Errorf("only the owning organization %s can change the readers of file %s", file.OwnerMSP, id)
+	}
+
+	readers, err := parseReaders(readersJSON)
+	if err != nil {
+		return err
+	}
+	approverOrgs := file.RequiredOrgs
+	if file.WorkflowID != "" {
+		workflow, err := readWorkflow(ctx, file.WorkflowID)
+		if err != nil {
+			return err
+		}
+		if workflow == nil {
+			return fmt.Errorf("workflow %s of file %s does not exist", file.WorkflowID, id)
+		}
+		if approverOrgs, err = workflowOrgs(workflow); err != nil {
+			return err
+		}
+	}
+	file.Readers = withOrgReaders(readers, append([]string{file.OwnerMSP}, approverOrgs...)...)
+
+	updatedFileJSON, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated file: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(id, updatedFileJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update file state: %v", err)
+	}
+
+	details := fmt.Sprintf("Organization %s set the readers of file %s to %s", mspID, file.Name, describeReaders(file.Readers))
+	if err := CreateAuditLog(ctx, id, "UPDATE_READERS", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileReadersUpdated, file)
+}
+
+// Replaces the editors of a file, who may register new versions on top of it alongside the
+// owner. New versions inherit the editors of their previous version. Only the owner may change them.
+func UpdateEditors(ctx contractapi.TransactionContextInterface, id string, editorsJSON string) error {
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("file does not exist: %s", id)
+	}
+	if err := requireLive(file); err != nil {
+		return err
+	}
+	if file.OwnerMSP == "" {
+		return fmt.Errorf("file %s predates ownership records and has no owner", id)
+	}
+
+	owner, err := isOwner(ctx, file)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		return fmt.Errorf("only the owner of file %s can change its editors", id)
+	}
+
+	editors, err := parseRules(editorsJSON, "editor")
+	if err != nil {
+		return err
+	}
+	file.Editors = editors
+
+	if err := putFile(ctx, file); err != nil {
+		return err
+	}
+
+	description := describeReaders(file.Editors)
+	if description == "" {
+		description = "the owner only"
+	}
+	details := fmt.Sprintf("Organization %s set the editors of file %s to %s", file.OwnerMSP, file.Name, description)
+	if err := CreateAuditLog(ctx, id, "UPDATE_EDITORS", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileEditorsUpdated, file)
+}
+
+// Renders reader rules for audit details, e.g. Org1MSP, Org2MSP[role=auditor]
+func describeReaders(readers []models.ReadRule) string {
+	description := ""
+	for i, rule := range readers {
+		if i > 0 {
+			description += ", "
+		}
+		description += rule.MSPID
+		if rule.Attribute != "" {
+			description += fmt.Sprintf("[%s=%s]", rule.Attribute, rule.Value)
+		}
+	}
+	return description
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestReadersLimitWhoSeesAFile(t *testing.T) {
+	owner := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=owner::CN=ca.org1"}
+	auditor := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=auditor::CN=ca.org2", attrs: map[string]string{"role": "auditor"}}
+	clerk := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=clerk::CN=ca.org2"}
+	start := time.Date(2025, 5, 5, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "salaries.xlsx", "QmHash", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, "", `[{"mspId":"Org2MSP","attribute":"role","value":"auditor"}]`, "")
+		return err
+	})
+
+	// Reports whether file1 is among the files QueryAllFiles returns to the identity.
+	// The mock stub's range scans also return composite keys, so other entries are ignored.
+	listsFile := func(txID string, identity *fakeIdentity) bool {
+		var files []models.File
+		p.endorse(t, txID, start, identity, func(ctx contractapi.TransactionContextInterface) error {
+			filesJSON, err := QueryAllFiles(ctx)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(filesJSON), &files)
+		})
+		for _, file := range files {
+			if file.ID == "file1" {
+				return true
+			}
+		}
+		return false
+	}
+	getFile := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := GetFileByID(ctx, "file1")
+		return err
+	}
+
+	if !listsFile("tx2", auditor) {
+		t.Fatal("auditor does not see the file")
+	}
+	if listsFile("tx3", clerk) {
+		t.Fatal("clerk without the auditor role sees the file")
+	}
+	if err := p.invoke("tx4", start, clerk, getFile); err == nil {
+		t.Fatal("clerk read the file by ID")
+	}
+
+	// Only the owning organization may change the readers
+	updateReaders := func(ctx contractapi.TransactionContextInterface) error {
+		return UpdateReaders(ctx, "file1", `[{"mspId":"Org2MSP"}]`)
+	}
+	if err := p.invoke("tx5", start, auditor, updateReaders); err == nil {
+		t.Fatal("a reader changed the readers of a file it does not own")
+	}
+	p.endorse(t, "tx6", start, owner, updateReaders)
+
+	if err := p.invoke("tx7", start, clerk, getFile); err != nil {
+		t.Fatalf("clerk cannot read the file after Org2 was granted access: %v", err)
+	}
+}
@@ -23,6 +23,14 @@ func ApproveFile(ctx contractapi.TransactionContextInterface, id string) error {
 	if err := json.Unmarshal(fileJSON, &file); err != nil {
 		return fmt.Errorf("failed to unmarshal file: %v", err)
 	}
+	if err := requireLive(&file); err != nil {
+		return err
+	}
+
+	// Rejected (or already approved) files take no further approvals
+	if file.Status != "PENDING" {
+		return fmt.Errorf("file %s is %s, only pending files can be approved", id, file.Status)
+	}
 
 	// Get the MSP ID of the approver
 	mspID, err := ctx.GetClientIdentity().GetMSPID()
@@ -30,19 +38,53 @@ func ApproveFile(ctx contractapi.TransactionContextInterface, id string) error {
 		return fmt.Errorf("failed to get MSP ID: %v", err)
 	}
 
-	// Check if already approved
-	for _, approval := range file.CurrentApprovals {
-		if approval == mspID {
-			return fmt.Errorf("organization has already approved this file")
+	// Only organizations named in the policy may approve; for workflow files that is the active stage's policy
+	approvalPolicy, err := filePolicy(&file)
+	if err != nil {
+		return err
+	}
+	if !approvalPolicy.Mentions(mspID) {
+		if file.WorkflowID != "" {
+			return fmt.Errorf("organization %s is not part of stage %d of the approval workflow of file %s", mspID, file.CurrentStage, id)
+		}
+		return fmt.Errorf("organization %s is not part of the approval policy of file %s", mspID, id)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Overdue files wait for ExpirePendingFiles, they take no more approvals meanwhile
+	passed, err := deadlinePassed(&file, txTime)
+	if err != nil {
+		return err
+	}
+	if passed {
+		return fmt.Errorf("approval deadline of file %s passed at %s", id, file.ApprovalDeadline)
+	}
+
+	approval, err := newApproval(ctx, mspID, txTime)
+	if err != nil {
+		return err
+	}
+
+	// Check if this user already approved; other users of the same org may still sign
+	for _, existing := range file.Approvals {
+		if existing.ClientID == approval.ClientID {
+			return fmt.Errorf("user has already approved this file")
 		}
 	}
 
 	// Add approval
-	file.CurrentApprovals = append(file.CurrentApprovals, mspID)
+	file.Approvals = append(file.Approvals, approval)
+	if !contains(file.CurrentApprovals, mspID) {
+		file.CurrentApprovals = append(file.CurrentApprovals, mspID)
+	}
 
-	// Check if we have all required approvals
-	if len(file.CurrentApprovals) == len(file.RequiredOrgs) {
-		file.Status = "APPROVED"
+	// Re-evaluate the policy with the new approval, advancing workflow files to their next stage
+	if err := settleApprovals(ctx, &file, approvalPolicy, txTime); err != nil {
+		return err
 	}
 
 	// Update state
@@ -57,11 +99,10 @@ func ApproveFile(ctx contractapi.TransactionContextInterface, id string) error {
 	}
 
 	// Create audit log entry
-	details := fmt.Sprintf("Organization %s approved file %s", mspID, file.Name)
+	details := fmt.Sprintf("User %s of organization %s approved file %s", approval.ClientID, mspID, file.Name)
 	if err := CreateAuditLog(ctx, id, "APPROVE", details); err != nil {
-		// Log the error but don't fail the transaction
-		fmt.Printf("WARNING: Failed to create audit log: %v\n", err)
+		return err
 	}
 
-	return nil
+	return emitFileEvent(ctx, models.EventFileApproved, &file)
 }
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestApprovalQuorumCountsDistinctUsers(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=bob::CN=ca.org1"}
+	carol := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=carol::CN=ca.org2"}
+	submittedAt := time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC)
+
+	register := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "report.pdf", "QmHash", "Org1", `{"size":42,"type":"application/pdf"}`, "",
+			`{"policyType":"CUSTOM","policy":"AND(Signers(2, 'Org1MSP'), 'Org2MSP')"}`, "", "", "")
+		return err
+	}
+	approve := func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "file1")
+	}
+	revoke := func(ctx contractapi.TransactionContextInterface) error {
+		return RevokeApproval(ctx, "file1", "signed the wrong draft")
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", submittedAt, alice, register)
+
+	// The submitter already counts as one Org1 signer and cannot sign twice
+	err := p.invoke("tx2", submittedAt.Add(time.Minute), alice, approve)
+	if err == nil || !strings.Contains(err.Error(), "already approved") {
+		t.Fatalf("second approval by the same user: got %v, want an already approved error", err)
+	}
+
+	p.endorse(t, "tx3", submittedAt.Add(2*time.Minute), carol, approve)
+	if file := p.file(t, "file1"); file.Status != "PENDING" {
+		t.Fatalf("status after one Org1 signer = %s, want PENDING", file.Status)
+	}
+
+	// Withdrawing alice's signature drops Org1 until another of its users signs
+	p.endorse(t, "tx4", submittedAt.Add(3*time.Minute), alice, revoke)
+	if file := p.file(t, "file1"); contains(file.CurrentApprovals, "Org1MSP") {
+		t.Fatalf("Org1MSP still listed after its only signer revoked: %v", file.CurrentApprovals)
+	}
+
+	p.endorse(t, "tx5", submittedAt.Add(4*time.Minute), bob, approve)
+	if file := p.file(t, "file1"); file.Status != "PENDING" {
+		t.Fatalf("status after one Org1 signer = %s, want PENDING", file.Status)
+	}
+
+	p.endorse(t, "tx6", submittedAt.Add(5*time.Minute), alice, approve)
+	file := p.file(t, "file1")
+	if file.Status != "APPROVED" {
+		t.Fatalf("status after two Org1 signers and Org2 = %s, want APPROVED", file.Status)
+	}
+	if len(file.Approvals) != 3 || len(file.Revocations) != 1 {
+		t.Fatalf("recorded %d approvals and %d revocations, want 3 and 1", len(file.Approvals), len(file.Revocations))
+	}
+	for _, approval := range file.Approvals {
+		if approval.ClientID == "" || approval.MSPID == "" || approval.Timestamp == "" {
+			t.Errorf("incomplete approval record %+v", approval)
+		}
+	}
+}
@@ -3,13 +3,48 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
+	"dltfm/pkg/models"
+
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// Records an action performed on a file
+// Index holding the last entry of each file's audit chain
+const auditHeadIndex = "auditHead~fileId"
+
+// Indexes finding audit entries across files. Keys are bucketed by UTC day so time-bounded
+// queries only visit the days in range; the value of each index key is the key of the entry.
+const (
+	auditOrgIndex    = "auditOrg~org~day~time~fileId~txId~action"
+	auditUserIndex   = "auditUser~user~day~time~fileId~txId~action"
+	auditActionIndex = "auditAction~action~day~time~fileId~txId"
+	auditDayIndex    = "auditDay~day~time~fileId~txId~action"
+)
+
+// Backfill progress name covering every cross-file audit index
+const auditIndexesBackfill = "auditIndexes"
+
+// Layout of the day attribute of the audit indexes
+const auditDayLayout = "2006-01-02"
+
+// Indexes written before entries were bucketed by day, removed by BackfillAuditIndexes
+var legacyAuditIndexes = []string{
+	"auditOrg~org~time~fileId~txId~action",
+	"auditUser~user~time~fileId~txId~action",
+	"auditAction~action~time~fileId~txId",
+}
+
+// Records an action performed on a file, linking it to the file's previous audit entry.
+// Callers must fail the transaction when this fails, or the action would go unaudited.
 func CreateAuditLog(ctx contractapi.TransactionContextInterface, fileID string, action string, details string) error {
+	tracker, ok := ctx.(auditHeadTracker)
+	if !ok {
+		return fmt.Errorf("transaction context cannot chain audit entries")
+	}
+
 	// Get the MSP ID of the submitter
 	mspID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
@@ -23,22 +58,34 @@ func CreateAuditLog(ctx contractapi.TransactionContextInterface, fileID string,
 		id = "unknown"
 	}
 
+	// Use the transaction timestamp so every endorsing peer writes the same entry
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	// An earlier entry of this transaction is not readable yet, the context remembers it
+	head, ok := tracker.pendingAuditHead(fileID)
+	if !ok {
+		if head, err = readAuditHead(ctx, fileID); err != nil {
+			return err
+		}
+	}
+
 	// Create the audit log entry
-	log := struct {
-		FileID    string `json:"fileId"`
-		Action    string `json:"action"`
-		Timestamp string `json:"timestamp"`
-		UserID    string `json:"userId"`
-		OrgID     string `json:"orgId"`
-		Details   string `json:"details,omitempty"`
-	}{
-		FileID:    fileID,
-		Action:    action,
-		Timestamp: time.Now().Format(time.RFC3339),
-		UserID:    id,
-		OrgID:     mspID,
-		Details:   details,
+	log := models.AuditLog{
+		FileID:       fileID,
+		Action:       action,
+		Timestamp:    txTime.Format(time.RFC3339),
+		UserID:       id,
+		OrgID:        mspID,
+		Details:      details,
+		TxID:         txID,
+		Sequence:     head.Sequence + 1,
+		PreviousHash: head.Hash,
 	}
+	log.Hash = log.ComputeHash()
 
 	logJSON, err := json.Marshal(log)
 	if err != nil {
@@ -46,12 +93,325 @@ func CreateAuditLog(ctx contractapi.TransactionContextInterface, fileID string,
 	}
 
 	// Create a composite key for the audit log
-	// Format: audit~fileId~timestamp to allow querying logs by file
-	timestamp := time.Now().UnixNano()
-	logKey, err := ctx.GetStub().CreateCompositeKey("audit", []string{fileID, fmt.Sprintf("%d", timestamp)})
+	// Format: audit~fileId~timestamp~txId~action to allow querying logs by file in order.
+	// The tx ID keeps keys unique across transactions, the action within one.
+	timeKey := fmt.Sprintf("%d", txTime.UnixNano())
+	logKey, err := ctx.GetStub().CreateCompositeKey("audit", []string{
+		fileID,
+		timeKey,
+		txID,
+		action,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create composite key: %v", err)
 	}
 
-	return ctx.GetStub().PutState(logKey, logJSON)
+	if err := ctx.GetStub().PutState(logKey, logJSON); err != nil {
+		return fmt.Errorf("failed to save audit log: %v", err)
+	}
+
+	// Index the entry for queries across files
+	if err := putAuditIndexes(ctx, logKey, timeKey, log); err != nil {
+		return err
+	}
+
+	head = models.AuditChainHead{Sequence: log.Sequence, Hash: log.Hash}
+	if err := putAuditHead(ctx, fileID, head); err != nil {
+		return err
+	}
+	tracker.setPendingAuditHead(fileID, head)
+
+	return nil
+}
+
+// Recomputes the audit chain of a file and reports the first entry that does not link up.
+// A chain whose last entries were removed is caught by comparing it with the stored head.
+func VerifyAuditChain(ctx contractapi.TransactionContextInterface, fileID string) (string, error) {
+	if err := requireAuditAccess(ctx, fileID); err != nil {
+		return "", err
+	}
+
+	logs, err := readAuditLogs(ctx, fileID)
+	if err != nil {
+		return "", err
+	}
+	head, err := readAuditHead(ctx, fileID)
+	if err != nil {
+		return "", err
+	}
+
+	report := models.AuditChainReport{FileID: fileID, Valid: true}
+	var chained []models.AuditLog
+	for _, log := range logs {
+		if log.Sequence == 0 && log.Hash == "" {
+			report.Unchained++
+			continue
+		}
+		chained = append(chained, log)
+	}
+
+	// Entries of one transaction share a timestamp, so key order alone does not place them
+	sort.SliceStable(chained, func(i, j int) bool { return chained[i].Sequence < chained[j].Sequence })
+
+	broken := func(sequence int, reason string) {
+		report.Valid = false
+		report.BrokenAt = sequence
+		report.Reason = reason
+	}
+
+	previous := models.AuditChainHead{}
+	for _, log := range chained {
+		report.Entries++
+
+		switch {
+		case log.Sequence != previous.Sequence+1:
+			broken(previous.Sequence+1, fmt.Sprintf("entry %d is missing, the next entry is %d", previous.Sequence+1, log.Sequence))
+		case log.PreviousHash != previous.Hash:
+			broken(log.Sequence, fmt.Sprintf("entry %d does not link to entry %d", log.Sequence, previous.Sequence))
+		case log.ComputeHash() != log.Hash:
+			broken(log.Sequence, fmt.Sprintf("entry %d was modified after it was written", log.Sequence))
+		}
+		if !report.Valid {
+			break
+		}
+
+		previous = models.AuditChainHead{Sequence: log.Sequence, Hash: log.Hash}
+	}
+
+	if report.Valid && previous != head {
+		broken(previous.Sequence+1, fmt.Sprintf("the chain ends at entry %d but its head records entry %d", previous.Sequence, head.Sequence))
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit chain report: %v", err)
+	}
+
+	return string(reportJSON), nil
+}
+
+// The audit trail of a file is as confidential as the file itself
+func requireAuditAccess(ctx contractapi.TransactionContextInterface, fileID string) error {
+	file, err := readFile(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return nil
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return err
+	}
+	if !caller.canRead(file) {
+		return fmt.Errorf("access denied: organization %s cannot read file %s", caller.mspID, fileID)
+	}
+	return nil
+}
+
+// Returns the audit entries of a file in key order
+func readAuditLogs(ctx contractapi.TransactionContextInterface, fileID string) ([]models.AuditLog, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("audit", []string{fileID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %v", err)
+	}
+	defer iterator.Close()
+
+	var logs []models.AuditLog
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate audit logs: %v", err)
+		}
+
+		var log models.AuditLog
+		if err := json.Unmarshal(response.Value, &log); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal audit log %s: %v", response.Key, err)
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, nil
+}
+
+// Returns the last committed entry of a file's audit chain, or the zero head if it has none
+func readAuditHead(ctx contractapi.TransactionContextInterface, fileID string) (models.AuditChainHead, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(auditHeadIndex, []string{fileID})
+	if err != nil {
+		return models.AuditChainHead{}, fmt.Errorf("failed to create audit head key: %v", err)
+	}
+
+	headJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return models.AuditChainHead{}, fmt.Errorf("failed to read audit head: %v", err)
+	}
+	if headJSON == nil {
+		return models.AuditChainHead{}, nil
+	}
+
+	var head models.AuditChainHead
+	if err := json.Unmarshal(headJSON, &head); err != nil {
+		return models.AuditChainHead{}, fmt.Errorf("failed to unmarshal audit head: %v", err)
+	}
+	return head, nil
+}
+
+func putAuditHead(ctx contractapi.TransactionContextInterface, fileID string, head models.AuditChainHead) error {
+	key, err := ctx.GetStub().CreateCompositeKey(auditHeadIndex, []string{fileID})
+	if err != nil {
+		return fmt.Errorf("failed to create audit head key: %v", err)
+	}
+
+	headJSON, err := json.Marshal(head)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit head: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, headJSON); err != nil {
+		return fmt.Errorf("failed to save audit head: %v", err)
+	}
+	return nil
+}
+
+// Adds an audit entry to the cross-file indexes. timeKey is the UnixNano time in the entry's key.
+func putAuditIndexes(ctx contractapi.TransactionContextInterface, logKey string, timeKey string, log models.AuditLog) error {
+	nanos, err := strconv.ParseInt(timeKey, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid time %q in audit key: %v", timeKey, err)
+	}
+	day := time.Unix(0, nanos).UTC().Format(auditDayLayout)
+
+	indexes := []struct {
+		name       string
+		attributes []string
+	}{
+		{auditOrgIndex, []string{log.OrgID, day, timeKey, log.FileID, log.TxID, log.Action}},
+		{auditUserIndex, []string{log.UserID, day, timeKey, log.FileID, log.TxID, log.Action}},
+		{auditActionIndex, []string{log.Action, day, timeKey, log.FileID, log.TxID}},
+		{auditDayIndex, []string{day, timeKey, log.FileID, log.TxID, log.Action}},
+	}
+	for _, index := range indexes {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(index.name, index.attributes)
+		if err != nil {
+			return fmt.Errorf("failed to create audit index key: %v", err)
+		}
+		if err := ctx.GetStub().PutState(indexKey, []byte(logKey)); err != nil {
+			return fmt.Errorf("failed to save audit index: %v", err)
+		}
+	}
+	return nil
+}
+
+// Adds audit entries written before the cross-file indexes, or before they were bucketed by
+// day, to them. Each run handles the entries of at most batchSize world state records and
+// resumes where the last one stopped; run it until it reports complete. Paginated queries
+// are only allowed in read-only transactions, so records are read with a plain range query
+// and each file's entries with a prefix query.
+func BackfillAuditIndexes(ctx contractapi.TransactionContextInterface, batchSize int32) (string, error) {
+	if batchSize <= 0 {
+		return "", fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	progress, err := readBackfill(ctx, auditIndexesBackfill)
+	if err != nil {
+		return "", err
+	}
+	if progress.Complete {
+		progressJSON, err := json.Marshal(progress)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal backfill progress: %v", err)
+		}
+		return string(progressJSON), nil
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange(progress.Bookmark, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get state range: %v", err)
+	}
+	defer iterator.Close()
+
+	progress.Complete = true
+	for read := int32(0); iterator.HasNext(); read++ {
+		response, err := iterator.Next()
+		if err != nil {
+			return "", fmt.Errorf("failed to iterate state: %v", err)
+		}
+		if read == batchSize {
+			progress.Bookmark = response.Key
+			progress.Complete = false
+			break
+		}
+
+		var file models.File
+		if err := json.Unmarshal(response.Value, &file); err != nil || file.ID == "" {
+			continue // Not a file
+		}
+		if err := backfillAuditEntries(ctx, response.Key, progress); err != nil {
+			return "", err
+		}
+	}
+
+	// Denials of transactions that target no file are audited under an empty file ID
+	if progress.Complete {
+		if err := backfillAuditEntries(ctx, "", progress); err != nil {
+			return "", err
+		}
+		progress.Bookmark = ""
+	}
+
+	return putBackfill(ctx, progress)
+}
+
+// Adds the audit entries of one file to the cross-file indexes and removes the index keys
+// of the entries written before the indexes were bucketed by day
+func backfillAuditEntries(ctx contractapi.TransactionContextInterface, fileID string, progress *models.BackfillProgress) error {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("audit", []string{fileID})
+	if err != nil {
+		return fmt.Errorf("failed to query audit logs: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to iterate audit logs: %v", err)
+		}
+
+		var log models.AuditLog
+		if err := json.Unmarshal(response.Value, &log); err != nil {
+			fmt.Printf("ERROR: Failed to unmarshal audit log %s: %v\n", response.Key, err)
+			continue // Skip invalid entries instead of failing
+		}
+
+		// Keys are audit~fileId~time, followed by the tx ID and action since those were added
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split audit key: %v", err)
+		}
+		if len(attributes) < 2 {
+			continue // Skip malformed keys
+		}
+		if err := putAuditIndexes(ctx, response.Key, attributes[1], log); err != nil {
+			return err
+		}
+
+		if len(attributes) == 4 {
+			legacy := [][]string{
+				{log.OrgID, attributes[1], attributes[0], attributes[2], attributes[3]},
+				{log.UserID, attributes[1], attributes[0], attributes[2], attributes[3]},
+				{log.Action, attributes[1], attributes[0], attributes[2]},
+			}
+			for i, index := range legacyAuditIndexes {
+				legacyKey, err := ctx.GetStub().CreateCompositeKey(index, legacy[i])
+				if err != nil {
+					return fmt.Errorf("failed to create audit index key: %v", err)
+				}
+				if err := ctx.GetStub().DelState(legacyKey); err != nil {
+					return fmt.Errorf("failed to remove audit index key: %v", err)
+				}
+			}
+		}
+		progress.Indexed++
+	}
+	return nil
 }
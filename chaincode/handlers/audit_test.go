@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=bob::CN=ca.org2"}
+	start := time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		return CreateWorkflow(ctx, "review", "Review", `[
+			{"name": "drafting", "policy": "'Org1MSP'"},
+			{"name": "sign-off", "policy": "'Org2MSP'"}
+		]`)
+	})
+
+	// Registering completes the first stage, so the transaction audits the file twice
+	p.endorse(t, "tx2", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "terms.pdf", "QmTerms", "Org1", `{}`, "",
+			`{"policyType":"WORKFLOW","workflow":"review"}`, "", "", "")
+		return err
+	})
+	p.endorse(t, "tx3", start.Add(time.Hour), bob, func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "file1")
+	})
+
+	verify := func(txID string) models.AuditChainReport {
+		var report models.AuditChainReport
+		p.endorse(t, txID, start.Add(time.Hour), alice, func(ctx contractapi.TransactionContextInterface) error {
+			reportJSON, err := VerifyAuditChain(ctx, "file1")
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(reportJSON), &report)
+		})
+		return report
+	}
+
+	if report := verify("tx4"); !report.Valid || report.Entries != 4 {
+		t.Fatalf("untouched chain: %+v, want 4 valid entries", report)
+	}
+
+	auditKey := func(action string) string {
+		for key := range p.stub.State {
+			if strings.HasPrefix(key, "\x00audit\x00file1\x00") && strings.Contains(key, action) {
+				return key
+			}
+		}
+		t.Fatalf("no %s audit entry for file1", action)
+		return ""
+	}
+
+	// Rewriting an entry without fixing its hash breaks the chain there
+	registerKey := auditKey("REGISTER")
+	original := p.stub.State[registerKey]
+	var entry models.AuditLog
+	if err := json.Unmarshal(original, &entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.OrgID = "Org2MSP"
+	p.stub.State[registerKey], _ = json.Marshal(entry)
+
+	if report := verify("tx5"); report.Valid || report.BrokenAt != entry.Sequence {
+		t.Fatalf("modified entry %d: %+v", entry.Sequence, report)
+	}
+	p.stub.State[registerKey] = original
+
+	// Dropping the latest entry leaves the chain short of its head
+	latestKey, latest := "", 0
+	for key, value := range p.stub.State {
+		if !strings.HasPrefix(key, "\x00audit\x00file1\x00") {
+			continue
+		}
+		if err := json.Unmarshal(value, &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Sequence > latest {
+			latestKey, latest = key, entry.Sequence
+		}
+	}
+	if err := p.stub.MockStub.DelState(latestKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := verify("tx6"); report.Valid {
+		t.Fatalf("truncated chain reported valid: %+v", report)
+	}
+}
+
+func TestQueryAuditLogsAcrossFiles(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=bob::CN=ca.org2"}
+	carol := &fakeIdentity{mspID: "Org3MSP", id: "x509::CN=carol::CN=ca.org3"}
+	start := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	register := func(id string, orgs string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			config := fmt.Sprintf(`{"requiredOrgs":%s,"policyType":"ALL_ORGS"}`, orgs)
+			_, err := RegisterFile(ctx, id, id+".pdf", "Qm"+id, "Org1", `{}`, "", config, "", `[]`, "")
+			return err
+		}
+	}
+	approve := func(id string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return ApproveFile(ctx, id)
+		}
+	}
+
+	// Each file is readable by the organizations that approve it
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, register("q2", `["Org1MSP","Org2MSP"]`))
+	p.endorse(t, "tx2", start, alice, register("q3", `["Org1MSP","Org2MSP"]`))
+	p.endorse(t, "tx3", start, alice, register("secret", `["Org1MSP","Org3MSP"]`))
+	p.endorse(t, "tx4", start.AddDate(0, 0, 10), bob, approve("q2"))
+	p.endorse(t, "tx5", start.AddDate(0, 4, 0), bob, approve("q3"))
+	p.endorse(t, "tx6", start.AddDate(0, 0, 20), carol, approve("secret"))
+
+	query := func(txID string, caller *fakeIdentity, filter models.AuditFilter) []models.AuditLog {
+		var page models.AuditLogPage
+		p.endorse(t, txID, start.AddDate(1, 0, 0), caller, func(ctx contractapi.TransactionContextInterface) error {
+			filterJSON, err := json.Marshal(filter)
+			if err != nil {
+				return err
+			}
+			pageJSON, err := QueryAuditLogs(ctx, string(filterJSON), 50, "")
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(pageJSON), &page)
+		})
+		return page.Logs
+	}
+
+	// Entries are indexed as they are written, and backfilling them again changes nothing
+	check := func(round string) {
+		// What did Org2MSP approve last quarter?
+		quarter := models.AuditFilter{Action: "APPROVE", From: "2025-07-01T00:00:00Z", To: "2025-09-30T23:59:59Z"}
+		byOrg2 := quarter
+		byOrg2.OrgID = "Org2MSP"
+		if logs := query(round+"-org2", bob, byOrg2); len(logs) != 1 || logs[0].FileID != "q2" {
+			t.Fatalf("%s: Org2's approvals last quarter: %v, want only q2", round, logs)
+		}
+
+		// Approvals of files the caller cannot read stay hidden
+		if logs := query(round+"-org1", alice, quarter); len(logs) != 2 {
+			t.Fatalf("%s: Org1 sees %d approvals last quarter, want 2", round, len(logs))
+		}
+		if logs := query(round+"-org3", carol, quarter); len(logs) != 1 || logs[0].FileID != "secret" {
+			t.Fatalf("%s: Org3 sees %v, want only the approval of secret", round, logs)
+		}
+
+		// Everything one user touched
+		logs := query(round+"-alice", alice, models.AuditFilter{UserID: alice.id})
+		if len(logs) != 3 {
+			t.Fatalf("%s: alice touched %d entries, want her 3 registrations", round, len(logs))
+		}
+		for _, log := range logs {
+			if log.UserID != alice.id || log.Action != "REGISTER" {
+				t.Fatalf("%s: user filter returned %s by %s", round, log.Action, log.UserID)
+			}
+		}
+	}
+	check("before-backfill")
+	p.backfillAuditIndexes(t, start.AddDate(1, 0, 0))
+	check("after-backfill")
+
+	if err := p.invoke("tx11", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := QueryAuditLogs(ctx, `{"from":"last quarter"}`, 50, "")
+		return err
+	}); err == nil {
+		t.Fatal("accepted a time bound that is not RFC3339")
+	}
+}
+
+func TestQueryAuditLogsPagesThroughDays(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	start := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "doc", "doc.pdf", "Qmdoc", "Org1", `{}`, "", `{"requiredOrgs":["Org1MSP"],"policyType":"ALL_ORGS"}`, "", `[]`, "")
+		return err
+	})
+
+	// An entry written by a chaincode version before the indexes, under audit~fileId~time
+	legacyTime := start.AddDate(0, 0, 2)
+	legacy := models.AuditLog{
+		FileID:    "doc",
+		Action:    "DOWNLOAD",
+		UserID:    alice.id,
+		OrgID:     alice.mspID,
+		Timestamp: legacyTime.Format(time.RFC3339),
+		TxID:      "legacy",
+	}
+	legacyJSON, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatalf("failed to marshal legacy entry: %v", err)
+	}
+	p.stub.MockTransactionStart("legacy")
+	legacyKey, _ := p.stub.CreateCompositeKey("audit", []string{"doc", fmt.Sprintf("%d", legacyTime.UnixNano())})
+	p.stub.PutState(legacyKey, legacyJSON)
+	p.stub.MockTransactionEnd("legacy")
+
+	// One download a day for ten days, two of them outside the queried week
+	for day := 1; day <= 10; day++ {
+		if day == 2 {
+			continue // The legacy entry's day
+		}
+		p.endorse(t, fmt.Sprintf("download-%d", day), start.AddDate(0, 0, day), alice, func(ctx contractapi.TransactionContextInterface) error {
+			return CreateAuditLog(ctx, "doc", "DOWNLOAD", "")
+		})
+	}
+	p.backfillAuditIndexes(t, start.AddDate(0, 1, 0))
+
+	// Days 2 to 8, read two entries at a time
+	filter := `{"action":"DOWNLOAD","from":"2025-07-03T00:00:00Z","to":"2025-07-09T23:59:59Z"}`
+	var logs []models.AuditLog
+	var fetched int32
+	bookmark := ""
+	for i := 0; ; i++ {
+		var page models.AuditLogPage
+		p.endorse(t, fmt.Sprintf("query-%d", i), start.AddDate(0, 1, 0), alice, func(ctx contractapi.TransactionContextInterface) error {
+			pageJSON, err := QueryAuditLogs(ctx, filter, 2, bookmark)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(pageJSON), &page)
+		})
+		if len(page.Logs) > 2 {
+			t.Fatalf("page %d has %d entries, want at most 2", i, len(page.Logs))
+		}
+		logs = append(logs, page.Logs...)
+		fetched += page.FetchedRecordsCount
+		if bookmark = page.Bookmark; bookmark == "" {
+			break
+		}
+	}
+
+	if len(logs) != 7 {
+		t.Fatalf("read %d downloads, want the 7 of days 2 to 8: %v", len(logs), logs)
+	}
+	if logs[0].TxID != "legacy" {
+		t.Fatalf("first download is %s, want the backfilled legacy entry", logs[0].TxID)
+	}
+	for i := 1; i < len(logs); i++ {
+		if logs[i].Timestamp <= logs[i-1].Timestamp {
+			t.Fatalf("downloads out of order: %s after %s", logs[i].Timestamp, logs[i-1].Timestamp)
+		}
+	}
+
+	// Only the queried days are read, not the entries before or after them
+	if fetched > 7 {
+		t.Fatalf("fetched %d index entries for 7 downloads", fetched)
+	}
+}
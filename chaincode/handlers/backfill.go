@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefix recording the progress of each index backfill, keyed by index name
+const backfillPrefix = "backfill"
+
+// Returns how far the backfill of an index has got, starting from nothing if it never ran
+func readBackfill(ctx contractapi.TransactionContextInterface, index string) (*models.BackfillProgress, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(backfillPrefix, []string{index})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backfill key: %v", err)
+	}
+
+	progressJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backfill progress: %v", err)
+	}
+	if progressJSON == nil {
+		return &models.BackfillProgress{Index: index}, nil
+	}
+
+	var progress models.BackfillProgress
+	if err := json.Unmarshal(progressJSON, &progress); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal backfill progress: %v", err)
+	}
+	return &progress, nil
+}
+
+// Saves the progress of a backfill and returns it as JSON for the caller
+func putBackfill(ctx contractapi.TransactionContextInterface, progress *models.BackfillProgress) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(backfillPrefix, []string{progress.Index})
+	if err != nil {
+		return "", fmt.Errorf("failed to create backfill key: %v", err)
+	}
+
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal backfill progress: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(key, progressJSON); err != nil {
+		return "", fmt.Errorf("failed to save backfill progress: %v", err)
+	}
+	return string(progressJSON), nil
+}
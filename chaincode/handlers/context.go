@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransactionContext is the context every transaction of the chaincode runs with.
+// A transaction cannot read back its own writes before it commits, so the context remembers
+// the audit chain heads the transaction has moved, for files audited more than once in it.
+type TransactionContext struct {
+	contractapi.TransactionContext
+	auditHeads map[string]models.AuditChainHead
+}
+
+// Implemented by contexts that can chain several audit entries of one transaction
+type auditHeadTracker interface {
+	pendingAuditHead(fileID string) (models.AuditChainHead, bool)
+	setPendingAuditHead(fileID string, head models.AuditChainHead)
+}
+
+func (c *TransactionContext) pendingAuditHead(fileID string) (models.AuditChainHead, bool) {
+	head, ok := c.auditHeads[fileID]
+	return head, ok
+}
+
+func (c *TransactionContext) setPendingAuditHead(fileID string, head models.AuditChainHead) {
+	if c.auditHeads == nil {
+		c.auditHeads = map[string]models.AuditChainHead{}
+	}
+	c.auditHeads[fileID] = head
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func assertSameWriteSet(t *testing.T, first map[string][]byte, second map[string][]byte) {
+	t.Helper()
+
+	if len(first) == 0 {
+		t.Fatal("transaction wrote nothing")
+	}
+	if len(first) != len(second) {
+		t.Fatalf("write sets differ in size: %d vs %d", len(first), len(second))
+	}
+
+	keys := make([]string, 0, len(first))
+	for key := range first {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		other, ok := second[key]
+		if !ok {
+			t.Fatalf("key %q written by the first peer only", key)
+		}
+		if !bytes.Equal(first[key], other) {
+			t.Fatalf("key %q differs between peers:\n%s\n%s", key, first[key], other)
+		}
+	}
+}
+
+func TestEndorsementIsDeterministicAcrossPeers(t *testing.T) {
+	org1User := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	org2User := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=user1::CN=ca.org2"}
+	submittedAt := time.Date(2025, 3, 14, 9, 26, 53, 589793238, time.UTC)
+
+	register := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "report.pdf", "QmHash", "Org1", `{"size":42,"type":"application/pdf"}`, "",
+			`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+		return err
+	}
+	approve := func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "file1")
+	}
+
+	org1Peer := newPeer("peer0.org1")
+	org2Peer := newPeer("peer0.org2")
+
+	// Give the peers different wall clocks, only the tx timestamp may leak into the write set
+	registerOnOrg1 := org1Peer.endorse(t, "tx1", submittedAt, org1User, register)
+	time.Sleep(10 * time.Millisecond)
+	registerOnOrg2 := org2Peer.endorse(t, "tx1", submittedAt, org1User, register)
+	assertSameWriteSet(t, registerOnOrg1, registerOnOrg2)
+
+	approvedAt := submittedAt.Add(time.Hour)
+	approveOnOrg1 := org1Peer.endorse(t, "tx2", approvedAt, org2User, approve)
+	time.Sleep(10 * time.Millisecond)
+	approveOnOrg2 := org2Peer.endorse(t, "tx2", approvedAt, org2User, approve)
+	assertSameWriteSet(t, approveOnOrg1, approveOnOrg2)
+}
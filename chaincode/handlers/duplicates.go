@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key index from content hash (IPFS CID) to every file registered with it.
+// Format: hash~cid~id so a hash lookup is a single prefix scan
+const hashIndex = "hash~cid~id"
+
+// How RegisterFile treats content that is already on the ledger under another ID
+const (
+	DuplicateReject = "REJECT" // Fail the registration
+	DuplicateAllow  = "ALLOW"  // Register as an unrelated file
+	DuplicateLink   = "LINK"   // Register and point DuplicateOf at the existing file
+)
+
+// Records id under its content hash in the hash index
+func putHashIndex(ctx contractapi.TransactionContextInterface, hash string, id string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(hashIndex, []string{hash, id})
+	if err != nil {
+		return fmt.Errorf("failed to create hash index key: %v", err)
+	}
+
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// Returns the IDs of every file the hash index holds under the given content hash. RegisterFile
+// indexes each file it writes; files written before the index existed are only in it once
+// BackfillHashIndex has reached them.
+func getFileIDsByHash(ctx contractapi.TransactionContextInterface, hash string) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(hashIndex, []string{hash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hash index: %v", err)
+	}
+	defer iterator.Close()
+
+	var ids []string
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate hash index: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split hash index key: %v", err)
+		}
+		if len(keyParts) != 2 {
+			continue // Skip malformed index entries
+		}
+
+		ids = append(ids, keyParts[1])
+	}
+
+	return ids, nil
+}
+
+// Adds files registered before the hash index existed to it. Each run reads at most batchSize
+// world state records and resumes where the last one stopped; run it until it reports
+// complete. Paginated queries are only allowed in read-only transactions, so the records are
+// read with a plain range query starting at the key the last run stopped at.
+func BackfillHashIndex(ctx contractapi.TransactionContextInterface, batchSize int32) (string, error) {
+	if batchSize <= 0 {
+		return "", fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	progress, err := readBackfill(ctx, hashIndex)
+	if err != nil {
+		return "", err
+	}
+	if progress.Complete {
+		progressJSON, err := json.Marshal(progress)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal backfill progress: %v", err)
+		}
+		return string(progressJSON), nil
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange(progress.Bookmark, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get state range: %v", err)
+	}
+	defer iterator.Close()
+
+	progress.Complete = true
+	for read := int32(0); iterator.HasNext(); read++ {
+		response, err := iterator.Next()
+		if err != nil {
+			return "", fmt.Errorf("failed to iterate state: %v", err)
+		}
+		if read == batchSize {
+			progress.Bookmark = response.Key
+			progress.Complete = false
+			break
+		}
+
+		var file models.File
+		if err := json.Unmarshal(response.Value, &file); err != nil || file.Hash == "" {
+			continue // Not a file, or one without content
+		}
+		if err := putHashIndex(ctx, file.Hash, file.ID); err != nil {
+			return "", err
+		}
+		progress.Indexed++
+	}
+	if progress.Complete {
+		progress.Bookmark = ""
+	}
+
+	return putBackfill(ctx, progress)
+}
+
+// Validates a duplicate policy argument, defaulting to ALLOW to keep older clients working
+func parseDuplicatePolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return DuplicateAllow, nil
+	case DuplicateReject, DuplicateAllow, DuplicateLink:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid duplicate policy: %s", policy)
+	}
+}
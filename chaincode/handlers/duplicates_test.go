@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestDuplicatePolicies(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	start := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	register := func(id string, duplicatePolicy string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, "report.pdf", "QmSame", "Org1", `{}`, "",
+				`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, duplicatePolicy, "", "")
+			return err
+		}
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, register("a", ""))
+
+	if err := p.invoke("tx2", start, alice, register("b", DuplicateReject)); err == nil || !strings.Contains(err.Error(), "already registered as file a") {
+		t.Fatalf("REJECT registered known content, err = %v", err)
+	}
+	p.endorse(t, "tx3", start, alice, register("c", DuplicateAllow))
+	p.endorse(t, "tx4", start, alice, register("d", DuplicateLink))
+
+	if got := p.file(t, "c").DuplicateOf; got != "" {
+		t.Errorf("ALLOW linked c to %s", got)
+	}
+	if got := p.file(t, "d").DuplicateOf; got != "a" {
+		t.Errorf("LINK points d at %q, want a", got)
+	}
+	if err := p.invoke("tx5", start, alice, register("e", "KEEP")); err == nil {
+		t.Error("accepted an unknown duplicate policy")
+	}
+
+	// The registration's audit entry names the duplicates and the policy applied
+	found := false
+	for key, value := range p.stub.State {
+		if !strings.HasPrefix(key, "\x00audit\x00d\x00") {
+			continue
+		}
+		var entry models.AuditLog
+		if err := json.Unmarshal(value, &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Action == "REGISTER" {
+			found = true
+			if !strings.Contains(entry.Details, "duplicate content of a, c, policy LINK") {
+				t.Errorf("audit details %q do not record the duplicates", entry.Details)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("no REGISTER audit entry for d")
+	}
+}
+
+func TestHashLookupsFindFilesRegisteredBeforeTheIndex(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	start := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	// Files written by older chaincode have no hash index entries
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		for i := 0; i < 3; i++ {
+			legacy := models.File{ID: fmt.Sprintf("legacy%d", i), Name: "old.pdf", Hash: fmt.Sprintf("QmOld%d", i), Status: "APPROVED", Version: 1}
+			legacyJSON, _ := json.Marshal(legacy)
+			if err := ctx.GetStub().PutState(legacy.ID, legacyJSON); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	byHash := func(txID string, hash string) string {
+		var fileJSON string
+		p.endorse(t, txID, start, alice, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			fileJSON, err = GetFileByHash(ctx, hash)
+			return err
+		})
+		var file models.File
+		if fileJSON != "" {
+			if err := json.Unmarshal([]byte(fileJSON), &file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return file.ID
+	}
+
+	// Lookups only use the index, which knows nothing of them yet
+	if got := byHash("tx2", "QmOld1"); got != "" {
+		t.Fatalf("hash lookup before the backfill found %q, want nothing", got)
+	}
+
+	// Two records per run: the three legacy files take two runs
+	var progress models.BackfillProgress
+	for run := 1; !progress.Complete; run++ {
+		if run > 3 {
+			t.Fatalf("backfill does not complete: %+v", progress)
+		}
+		p.endorse(t, fmt.Sprintf("backfill%d", run), start, alice, func(ctx contractapi.TransactionContextInterface) error {
+			progressJSON, err := BackfillHashIndex(ctx, 2)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(progressJSON), &progress)
+		})
+	}
+	if progress.Indexed != 3 {
+		t.Fatalf("backfill indexed %d files, want 3", progress.Indexed)
+	}
+
+	for i := 0; i < 3; i++ {
+		key, _ := p.stub.CreateCompositeKey(hashIndex, []string{fmt.Sprintf("QmOld%d", i), fmt.Sprintf("legacy%d", i)})
+		if _, ok := p.stub.State[key]; !ok {
+			t.Errorf("legacy%d was not indexed", i)
+		}
+	}
+
+	// A completed backfill leaves lookups to the index and writes nothing more
+	if writes := p.endorse(t, "tx4", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := BackfillHashIndex(ctx, 2)
+		return err
+	}); len(writes) != 0 {
+		t.Fatalf("completed backfill wrote %d keys", len(writes))
+	}
+	if got := byHash("tx5", "QmOld0"); got != "legacy0" {
+		t.Fatalf("hash lookup after the backfill found %q, want legacy0", got)
+	}
+	if err := p.invoke("tx6", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "copy", "old.pdf", "QmOld2", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, DuplicateReject, "", "")
+		return err
+	}); err == nil {
+		t.Fatal("REJECT missed content registered before the index")
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"dltfm/pkg/models"
+	"dltfm/pkg/models/policy"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Policy type for files whose approval rule is given as a policy expression
+const PolicyTypeCustom = "CUSTOM"
+
+// Builds the approval policy for a new registration.
+// CUSTOM configs carry an expression, the older policy types are expressed over RequiredOrgs.
+func buildPolicy(config EndorsementConfig) (*policy.Policy, error) {
+	switch config.PolicyType {
+	case PolicyTypeCustom:
+		if config.Policy == "" {
+			return nil, fmt.Errorf("policy type %s requires a policy expression", PolicyTypeCustom)
+		}
+		parsed, err := policy.Parse(config.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endorsement policy: %v", err)
+		}
+		return parsed, nil
+	case "ANY_ORG", "ALL_ORGS", "SPECIFIC_ORGS":
+		orgs := uniqueOrgs(config.RequiredOrgs)
+		if len(orgs) == 0 {
+			return nil, fmt.Errorf("policy type %s requires at least one organization", config.PolicyType)
+		}
+		if config.PolicyType == "ANY_ORG" {
+			return policy.AnyOf(orgs), nil
+		}
+		return policy.AllOf(orgs), nil
+	default:
+		return nil, fmt.Errorf("invalid policy type: %s", config.PolicyType)
+	}
+}
+
+// Returns the approval policy of a stored file.
+// Files registered before policies were stored get theirs rebuilt from the policy type.
+func filePolicy(file *models.File) (*policy.Policy, error) {
+	if file.EndorsementPolicy != "" {
+		parsed, err := policy.Parse(file.EndorsementPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("stored endorsement policy of file %s is invalid: %v", file.ID, err)
+		}
+		return parsed, nil
+	}
+
+	return buildPolicy(EndorsementConfig{
+		RequiredOrgs: file.RequiredOrgs,
+		PolicyType:   file.EndorsementType,
+	})
+}
+
+// Builds the approval record for the submitting user
+func newApproval(ctx contractapi.TransactionContextInterface, mspID string, txTime time.Time) (models.Approval, error) {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return models.Approval{}, fmt.Errorf("failed to get client ID: %v", err)
+	}
+
+	// Idemix identities have no certificate, the client ID still tells signers apart
+	var subject string
+	if cert, err := ctx.GetClientIdentity().GetX509Certificate(); err == nil && cert != nil {
+		subject = cert.Subject.String()
+	}
+
+	return models.Approval{
+		ClientID:  clientID,
+		MSPID:     mspID,
+		Subject:   subject,
+		Timestamp: txTime.Format(time.RFC3339),
+	}, nil
+}
+
+// Lists the MSP ID of every signer, one entry per approval, for policy evaluation.
+// Organization-level approvals recorded before per-user approvals count as a single signer.
+func approvalSigners(file *models.File) []string {
+	signers := make([]string, 0, len(file.Approvals)+len(file.CurrentApprovals))
+	recorded := map[string]bool{}
+	for _, approval := range file.Approvals {
+		signers = append(signers, approval.MSPID)
+		recorded[approval.MSPID] = true
+	}
+	for _, mspID := range file.CurrentApprovals {
+		if !recorded[mspID] {
+			signers = append(signers, mspID)
+		}
+	}
+	return signers
+}
+
+// Removes duplicate and empty MSP IDs, sorting the result
+func uniqueOrgs(orgs []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, org := range orgs {
+		if org != "" && !seen[org] {
+			seen[org] = true
+			unique = append(unique, org)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
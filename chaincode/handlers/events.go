@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Emits a file event for the transaction. Pass every changed file when there is more than one;
+// the first is the one the event is about.
+func emitFileEvent(ctx contractapi.TransactionContextInterface, name string, file *models.File, others ...*models.File) error {
+	event := models.FileEvent{
+		FileID:  file.ID,
+		Version: file.Version,
+		Status:  file.Status,
+	}
+	if len(others) > 0 {
+		for _, changed := range append([]*models.File{file}, others...) {
+			event.Files = append(event.Files, models.FileEventRef{
+				FileID:  changed.ID,
+				Version: changed.Version,
+				Status:  changed.Status,
+			})
+		}
+	}
+
+	var err error
+	if event.ActorMSP, event.TxID, event.Timestamp, err = eventOrigin(ctx); err != nil {
+		return err
+	}
+	return setEvent(ctx, name, event)
+}
+
+// Emits an event about something other than a file, such as a workflow or an access policy
+func emitLedgerEvent(ctx contractapi.TransactionContextInterface, name string, subject string, details string) error {
+	event := models.LedgerEvent{
+		Subject: subject,
+		Details: details,
+	}
+
+	var err error
+	if event.ActorMSP, event.TxID, event.Timestamp, err = eventOrigin(ctx); err != nil {
+		return err
+	}
+	return setEvent(ctx, name, event)
+}
+
+// Returns the submitting organization, transaction ID and transaction time every event carries
+func eventOrigin(ctx contractapi.TransactionContextInterface) (string, string, string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return mspID, ctx.GetStub().GetTxID(), txTime.Format(time.RFC3339), nil
+}
+
+func setEvent(ctx contractapi.TransactionContextInterface, name string, event interface{}) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", name, err)
+	}
+
+	if err := ctx.GetStub().SetEvent(name, payload); err != nil {
+		return fmt.Errorf("failed to set %s event: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestLifecycleChangesEmitFileEvents(t *testing.T) {
+	org1 := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	org2 := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=user1::CN=ca.org2"}
+	start := time.Date(2025, 11, 10, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	fileEvent := func(want string) *models.FileEvent {
+		t.Helper()
+
+		if p.stub.event == nil {
+			t.Fatalf("no event emitted, want %s", want)
+		}
+		if p.stub.event.name != want {
+			t.Fatalf("emitted %s, want %s", p.stub.event.name, want)
+		}
+		decoded, err := models.DecodeEvent(p.stub.event.name, p.stub.event.payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decoded.(*models.FileEvent)
+	}
+
+	p.endorse(t, "tx1", start, org1, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "contract.pdf", "QmEvents", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+		return err
+	})
+	event := fileEvent(models.EventFileRegistered)
+	if event.FileID != "file1" || event.Version != 1 || event.Status != "PENDING" {
+		t.Fatalf("registration event = %+v", event)
+	}
+	if event.ActorMSP != "Org1MSP" || event.TxID != "tx1" || event.Timestamp != start.Format(time.RFC3339) {
+		t.Fatalf("registration event origin = %+v", event)
+	}
+
+	// The registering organization has approved already, so Org2's approval completes the file
+	p.endorse(t, "tx2", start, org2, func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "file1")
+	})
+	if event := fileEvent(models.EventFileApproved); event.Status != "APPROVED" || event.ActorMSP != "Org2MSP" {
+		t.Fatalf("approval event = %+v", event)
+	}
+
+	// A failed transaction emits nothing
+	if err := p.invoke("tx3", start, org2, func(ctx contractapi.TransactionContextInterface) error {
+		return ArchiveFile(ctx, "file1", "done", false)
+	}); err == nil {
+		t.Fatal("a user who does not own the file archived it")
+	}
+	if p.stub.event != nil {
+		t.Fatalf("failed transaction emitted %s", p.stub.event.name)
+	}
+}
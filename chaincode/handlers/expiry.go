@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key index of pending files by approval deadline.
+// Format: deadline~time~id; deadlines are stored as fixed-width RFC3339 UTC,
+// so a scan of the whole index returns them in chronological order.
+const deadlineIndex = "deadline~time~id"
+
+// Parses an approval deadline from an endorsement config, returning it in UTC
+func parseDeadline(deadline string, txTime time.Time) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, deadline)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid approval deadline %q, expected RFC3339: %v", deadline, err)
+	}
+	if !parsed.After(txTime) {
+		return time.Time{}, fmt.Errorf("approval deadline %s is not in the future", deadline)
+	}
+	return parsed.UTC(), nil
+}
+
+// Reports whether the file's approval deadline has passed at txTime
+func deadlinePassed(file *models.File, txTime time.Time) (bool, error) {
+	if file.ApprovalDeadline == "" {
+		return false, nil
+	}
+	deadline, err := time.Parse(time.RFC3339, file.ApprovalDeadline)
+	if err != nil {
+		return false, fmt.Errorf("stored approval deadline of file %s is invalid: %v", file.ID, err)
+	}
+	return !txTime.Before(deadline), nil
+}
+
+// Records a pending file under its deadline in the deadline index
+func putDeadlineIndex(ctx contractapi.TransactionContextInterface, deadline string, id string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(deadlineIndex, []string{deadline, id})
+	if err != nil {
+		return fmt.Errorf("failed to create deadline index key: %v", err)
+	}
+
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// Moves every pending file whose approval deadline has passed to EXPIRED.
+// Any organization may call it; the cut-off is the transaction timestamp,
+// so every endorsing peer expires the same files.
+func ExpirePendingFiles(ctx contractapi.TransactionContextInterface) (string, error) {
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	now := txTime.Format(time.RFC3339)
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(deadlineIndex, []string{})
+	if err != nil {
+		return "", fmt.Errorf("failed to query deadline index: %v", err)
+	}
+	defer iterator.Close()
+
+	report := models.ExpiryReport{Expired: []string{}}
+	var expired []*models.File
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return "", fmt.Errorf("failed to iterate deadline index: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return "", fmt.Errorf("failed to split deadline index key: %v", err)
+		}
+		if len(keyParts) != 2 {
+			continue // Skip malformed index entries
+		}
+
+		// Entries are in deadline order, everything after this one is still open
+		if keyParts[0] > now {
+			break
+		}
+
+		// The deadline is settled one way or the other, drop the entry
+		if err := ctx.GetStub().DelState(response.Key); err != nil {
+			return "", fmt.Errorf("failed to delete deadline index entry: %v", err)
+		}
+
+		file, err := readFile(ctx, keyParts[1])
+		if err != nil {
+			return "", err
+		}
+		if file == nil || file.Status != "PENDING" {
+			continue // Approved, rejected or removed before the deadline
+		}
+
+		file.Status = "EXPIRED"
+
+		fileJSON, err := json.Marshal(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal updated file: %v", err)
+		}
+		if err := ctx.GetStub().PutState(file.ID, fileJSON); err != nil {
+			return "", fmt.Errorf("failed to update file state: %v", err)
+		}
+
+		details := fmt.Sprintf("File %s expired, approval deadline %s passed without meeting policy %s", file.Name, file.ApprovalDeadline, file.EndorsementPolicy)
+		if err := CreateAuditLog(ctx, file.ID, "EXPIRE", details); err != nil {
+			return "", err
+		}
+
+		report.Expired = append(report.Expired, file.ID)
+		expired = append(expired, file)
+	}
+
+	if len(expired) > 0 {
+		if err := emitFileEvent(ctx, models.EventFileStatusChanged, expired[0], expired[1:]...); err != nil {
+			return "", err
+		}
+	}
+
+	report.Count = len(report.Expired)
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal expiry report: %v", err)
+	}
+
+	return string(reportJSON), nil
+}
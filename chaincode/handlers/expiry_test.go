@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestPendingFilesExpireAfterDeadline(t *testing.T) {
+	org1User := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	org2User := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=user1::CN=ca.org2"}
+	start := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+
+	register := func(id string, deadline time.Time) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			config := `{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS"}`
+			if !deadline.IsZero() {
+				config = fmt.Sprintf(`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS","deadline":%q}`, deadline.Format(time.RFC3339))
+			}
+			_, err := RegisterFile(ctx, id, id+".pdf", "Qm"+id, "Org1", `{"size":42,"type":"application/pdf"}`, "", config, "", "", "")
+			return err
+		}
+	}
+	approve := func(id string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return ApproveFile(ctx, id)
+		}
+	}
+
+	var report models.ExpiryReport
+	expire := func(ctx contractapi.TransactionContextInterface) error {
+		reportJSON, err := ExpirePendingFiles(ctx)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(reportJSON), &report)
+	}
+
+	p := newPeer("peer0.org1")
+	if err := p.invoke("tx0", start, org1User, register("past", start.Add(-time.Hour))); err == nil {
+		t.Fatal("registering with a deadline in the past succeeded")
+	}
+
+	p.endorse(t, "tx1", start, org1User, register("overdue", start.Add(time.Hour)))
+	p.endorse(t, "tx2", start, org1User, register("approved", start.Add(time.Hour)))
+	p.endorse(t, "tx3", start, org1User, register("open", start.Add(48*time.Hour)))
+	p.endorse(t, "tx4", start, org1User, register("nodeadline", time.Time{}))
+
+	p.endorse(t, "tx5", start.Add(30*time.Minute), org2User, approve("approved"))
+
+	// The deadline itself is already too late
+	if err := p.invoke("tx6", start.Add(time.Hour), org2User, approve("overdue")); err == nil {
+		t.Fatal("approval at the deadline succeeded")
+	}
+
+	p.endorse(t, "tx7", start.Add(2*time.Hour), org2User, expire)
+	if report.Count != 1 || len(report.Expired) != 1 || report.Expired[0] != "overdue" {
+		t.Fatalf("first sweep expired %v (count %d), want [overdue]", report.Expired, report.Count)
+	}
+
+	wantStatus := map[string]string{
+		"overdue":    "EXPIRED",
+		"approved":   "APPROVED",
+		"open":       "PENDING",
+		"nodeadline": "PENDING",
+	}
+	for id, want := range wantStatus {
+		if got := p.file(t, id).Status; got != want {
+			t.Errorf("file %s is %s, want %s", id, got, want)
+		}
+	}
+
+	// A second sweep finds nothing new
+	p.endorse(t, "tx8", start.Add(3*time.Hour), org1User, expire)
+	if report.Count != 0 {
+		t.Fatalf("second sweep expired %v, want nothing", report.Expired)
+	}
+}
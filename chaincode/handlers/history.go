@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// Returns every committed value of a file's key, oldest first, each with the fields that
+// changed since the one before. Unlike the audit log this comes from the peer's history
+// database, so it shows what was written whatever the chaincode recorded about it.
+func GetFileHistory(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if file == nil {
+		return "", fmt.Errorf("file does not exist: %s", id)
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !caller.canRead(file) {
+		return "", fmt.Errorf("access denied: organization %s cannot read file %s", caller.mspID, id)
+	}
+
+	iterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return "", fmt.Errorf("failed to query history of file %s: %v", id, err)
+	}
+	defer iterator.Close()
+
+	// The peer returns the newest value first
+	var values [][]byte
+	history := []models.FileHistoryEntry{}
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			return "", fmt.Errorf("failed to iterate history of file %s: %v", id, err)
+		}
+
+		entry := models.FileHistoryEntry{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			entry.Timestamp = modification.Timestamp.AsTime().UTC().Format(time.RFC3339Nano)
+		}
+		if !modification.IsDelete {
+			var state models.File
+			if err := json.Unmarshal(modification.Value, &state); err != nil {
+				return "", fmt.Errorf("failed to unmarshal file %s as of tx %s: %v", id, modification.TxId, err)
+			}
+			entry.File = &state
+		}
+
+		history = append([]models.FileHistoryEntry{entry}, history...)
+		values = append([][]byte{modification.Value}, values...)
+	}
+
+	for i := range history {
+		var previous []byte
+		if i > 0 {
+			previous = values[i-1]
+		}
+		if history[i].Changes, err = models.DiffFields(previous, values[i]); err != nil {
+			return "", fmt.Errorf("failed to compare file %s as of tx %s: %v", id, history[i].TxID, err)
+		}
+	}
+
+	historyJSON, err := json.Marshal(history)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal file history: %v", err)
+	}
+
+	return string(historyJSON), nil
+}
+
+// Returns a file as it was committed at asOf (RFC3339, optionally with fractional seconds),
+// or nothing when it did not exist yet. Whether the caller may see it is decided by the file's
+// current readers, so removing an organization also hides the file's past from it. Times are
+// transaction timestamps, which the submitting client chooses within the peers' tolerance, so
+// the view is exact only to that tolerance.
+func GetFileByIDAsOf(ctx contractapi.TransactionContextInterface, id string, asOf string) (string, error) {
+	asOfTime, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil {
+		return "", fmt.Errorf("invalid as of time %q, expected RFC3339: %v", asOf, err)
+	}
+
+	current, err := readFile(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if current == nil {
+		return "", nil
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !caller.canRead(current) {
+		return "", fmt.Errorf("access denied: organization %s cannot read file %s", caller.mspID, id)
+	}
+
+	file, err := fileAsOf(ctx, id, asOfTime)
+	if err != nil {
+		return "", err
+	}
+	if file == nil {
+		return "", nil // Like GetFileByID, an empty result means the file was not found
+	}
+
+	fileJSON, err := json.Marshal(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal file: %v", err)
+	}
+
+	return string(fileJSON), nil
+}
+
+// Returns one page of files as they were at asOf, in the same shape as QueryFilesPage.
+// The page runs over the current keys, which include every file ever registered since
+// files are tombstoned rather than removed; files registered later are skipped and the page
+// is filled from the keys after them.
+func QueryFilesAsOfPage(ctx contractapi.TransactionContextInterface, asOf string, pageSize int32, bookmark string, filter string) (string, error) {
+	asOfTime, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil {
+		return "", fmt.Errorf("invalid as of time %q, expected RFC3339: %v", asOf, err)
+	}
+	if pageSize <= 0 {
+		return "", fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	var fileFilter models.FileFilter
+	if filter != "" {
+		if err := json.Unmarshal([]byte(filter), &fileFilter); err != nil {
+			return "", fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	page := models.FilePage{Files: []models.File{}}
+	for {
+		// Never fetch more than the page has room for, so the bookmark stays exact
+		fetched, next, err := scanFiles(ctx, pageSize-int32(len(page.Files)), bookmark, func(key string, current models.File) (*models.File, error) {
+			if !caller.canRead(&current) {
+				return nil, nil
+			}
+			file, err := fileAsOf(ctx, key, asOfTime)
+			if err != nil || file == nil || !fileFilter.Matches(*file) {
+				return nil, err
+			}
+			return file, nil
+		}, &page)
+		if err != nil {
+			return "", err
+		}
+		page.FetchedRecordsCount += fetched
+		bookmark = next
+
+		if bookmark == "" || int32(len(page.Files)) == pageSize {
+			break
+		}
+	}
+	page.Bookmark = bookmark
+
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal files page: %v", err)
+	}
+
+	return string(pageJSON), nil
+}
+
+// Replays the history of a file key up to asOf, returning the state committed last by then.
+// Timestamps are chosen by the submitting clients, so they need not grow in commit order; the
+// value that was current at asOf is the last one committed with a timestamp not after it.
+func fileAsOf(ctx contractapi.TransactionContextInterface, id string, asOf time.Time) (*models.File, error) {
+	iterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history of file %s: %v", id, err)
+	}
+	defer iterator.Close()
+
+	// The peer returns the newest value first
+	var latest *queryresult.KeyModification
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate history of file %s: %v", id, err)
+		}
+		if modification.Timestamp != nil && !modification.Timestamp.AsTime().After(asOf) {
+			latest = modification
+			break
+		}
+	}
+
+	if latest == nil || latest.IsDelete {
+		return nil, nil
+	}
+
+	var file models.File
+	if err := json.Unmarshal(latest.Value, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal file %s as of tx %s: %v", id, latest.TxId, err)
+	}
+	return &file, nil
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestFileHistoryDiffsCommittedStates(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=bob::CN=ca.org2"}
+	carol := &fakeIdentity{mspID: "Org3MSP", id: "x509::CN=carol::CN=ca.org3"}
+	start := time.Date(2025, 8, 4, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "budget.xlsx", "QmBudget", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+		return err
+	})
+	p.endorse(t, "tx2", start.Add(time.Hour), bob, func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "file1")
+	})
+
+	var history []models.FileHistoryEntry
+	p.endorse(t, "tx3", start.Add(2*time.Hour), alice, func(ctx contractapi.TransactionContextInterface) error {
+		historyJSON, err := GetFileHistory(ctx, "file1")
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(historyJSON), &history)
+	})
+
+	if len(history) != 2 || history[0].TxID != "tx1" || history[1].TxID != "tx2" {
+		t.Fatalf("history %+v, want tx1 then tx2", history)
+	}
+	if history[1].Timestamp != start.Add(time.Hour).Format(time.RFC3339Nano) || history[1].File.Status != "APPROVED" {
+		t.Fatalf("approval entry %+v", history[1])
+	}
+
+	changed := map[string]models.FieldChange{}
+	for _, change := range history[1].Changes {
+		changed[change.Field] = change
+	}
+	status, ok := changed["status"]
+	if !ok || string(status.Before) != `"PENDING"` || string(status.After) != `"APPROVED"` {
+		t.Fatalf("status change %+v, want PENDING to APPROVED", status)
+	}
+	if _, ok := changed["name"]; ok {
+		t.Fatal("the unchanged name is reported as a change")
+	}
+
+	if err := p.invoke("tx4", start, carol, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := GetFileHistory(ctx, "file1")
+		return err
+	}); err == nil {
+		t.Fatal("an organization that cannot read the file got its history")
+	}
+}
+
+func TestRegistryAsOf(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=bob::CN=ca.org2"}
+	start := time.Date(2025, 8, 4, 9, 0, 0, 0, time.UTC)
+
+	register := func(id string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, id+".pdf", "Qm"+id, "Org1", `{}`, "",
+				`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+			return err
+		}
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, register("early"))
+	p.endorse(t, "tx2", start.Add(2*time.Hour), bob, func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "early")
+	})
+	p.endorse(t, "tx3", start.Add(3*time.Hour), alice, register("late"))
+	p.endorse(t, "tx3b", start.Add(3*time.Hour), alice, register("draft"))
+
+	asOf := start.Add(time.Hour).Format(time.RFC3339)
+
+	var file models.File
+	p.endorse(t, "tx4", start.Add(4*time.Hour), alice, func(ctx contractapi.TransactionContextInterface) error {
+		fileJSON, err := GetFileByIDAsOf(ctx, "early", asOf)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(fileJSON), &file)
+	})
+	if file.Status != "PENDING" || len(file.CurrentApprovals) != 1 {
+		t.Fatalf("early as of %s: status %s approvals %v, want PENDING with Org1's approval only", asOf, file.Status, file.CurrentApprovals)
+	}
+
+	var page models.FilePage
+	p.endorse(t, "tx5", start.Add(4*time.Hour), alice, func(ctx contractapi.TransactionContextInterface) error {
+		pageJSON, err := QueryFilesAsOfPage(ctx, asOf, 50, "", "")
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(pageJSON), &page)
+	})
+	if len(page.Files) != 1 || page.Files[0].ID != "early" {
+		t.Fatalf("registry as of %s lists %v, want only early", asOf, page.Files)
+	}
+
+	// A file registered later sorts first, the page is still filled past it
+	p.endorse(t, "tx5b", start.Add(4*time.Hour), alice, func(ctx contractapi.TransactionContextInterface) error {
+		pageJSON, err := QueryFilesAsOfPage(ctx, asOf, 1, "", "")
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(pageJSON), &page)
+	})
+	if len(page.Files) != 1 || page.Files[0].ID != "early" {
+		t.Fatalf("registry page of one as of %s lists %v, want early", asOf, page.Files)
+	}
+
+	p.endorse(t, "tx6", start.Add(4*time.Hour), alice, func(ctx contractapi.TransactionContextInterface) error {
+		fileJSON, err := GetFileByIDAsOf(ctx, "late", asOf)
+		if err != nil {
+			return err
+		}
+		if fileJSON != "" {
+			t.Fatalf("late as of %s = %s, want not found", asOf, fileJSON)
+		}
+		return nil
+	})
+}
+
+func TestRegistryAsOfWithinOneSecond(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=bob::CN=ca.org2"}
+	start := time.Date(2025, 8, 4, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start.Add(100*time.Millisecond), alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "doc", "doc.pdf", "Qmdoc", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP","Org2MSP","Org3MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+		return err
+	})
+	p.endorse(t, "tx2", start.Add(700*time.Millisecond), bob, func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "doc")
+	})
+
+	// A client clock behind the last one commits a timestamp earlier than the value it replaces
+	p.endorse(t, "tx3", start.Add(500*time.Millisecond), bob, func(ctx contractapi.TransactionContextInterface) error {
+		return RevokeApproval(ctx, "doc", "approved the wrong draft")
+	})
+
+	approvalsAsOf := func(txID string, asOf time.Time) []string {
+		var file models.File
+		p.endorse(t, txID, start.Add(time.Hour), alice, func(ctx contractapi.TransactionContextInterface) error {
+			fileJSON, err := GetFileByIDAsOf(ctx, "doc", asOf.Format(time.RFC3339Nano))
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(fileJSON), &file)
+		})
+		return file.CurrentApprovals
+	}
+
+	// Fractions of a second tell apart transactions within the same second
+	if approvals := approvalsAsOf("tx4", start.Add(300*time.Millisecond)); len(approvals) != 1 {
+		t.Fatalf("approvals 300ms in: %v, want Org1's only", approvals)
+	}
+
+	// The revocation was committed last, so it is current at any time from its own timestamp on,
+	// even after the approval's later timestamp
+	for i, asOf := range []time.Time{start.Add(600 * time.Millisecond), start.Add(800 * time.Millisecond)} {
+		if approvals := approvalsAsOf(fmt.Sprintf("tx%d", 5+i), asOf); len(approvals) != 1 {
+			t.Fatalf("approvals as of %s: %v, want Org2's revoked", asOf.Format(time.RFC3339Nano), approvals)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+
+	"dltfm/pkg/models"
+	"dltfm/pkg/models/policy"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// Largest number of organizations a key endorsement policy is derived for. Every subset of
+// them is evaluated against the approval policy.
+const maxKeyEndorsementOrgs = 16
+
+// Sets the key-level endorsement policy of a file to the peers of the organizations its approval
+// policy needs. Peers then reject any later update to the file key that those organizations did
+// not endorse, so approvals cannot be forged by tampering with a single peer.
+//
+// The approval policy counts signers, the key policy counts organizations: OutOf(2, 'Org1MSP',
+// 'Org2MSP', 'Org3MSP') asks for peers of any two of the three, so a file stays writable while
+// one organization's peers are down, and Signers(2, 'Org1MSP') needs a single Org1MSP peer.
+func setFileEndorsementPolicy(ctx contractapi.TransactionContextInterface, file *models.File) error {
+	approvalPolicy, err := filePolicy(file)
+	if err != nil {
+		return err
+	}
+
+	envelope, err := keyEndorsementPolicy(approvalPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to derive key endorsement policy of file %s: %v", file.ID, err)
+	}
+
+	policyBytes, err := proto.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key endorsement policy: %v", err)
+	}
+
+	if err := ctx.GetStub().SetStateValidationParameter(file.ID, policyBytes); err != nil {
+		return fmt.Errorf("failed to set key endorsement policy of file %s: %v", file.ID, err)
+	}
+
+	return nil
+}
+
+// Builds the signature policy over organization peers that is satisfied exactly when enough
+// organizations are present to satisfy the approval policy, with as many signers each as it needs.
+func keyEndorsementPolicy(approvalPolicy *policy.Policy) (*common.SignaturePolicyEnvelope, error) {
+	orgs := approvalPolicy.Orgs()
+	if len(orgs) == 0 {
+		return nil, fmt.Errorf("the approval policy names no organizations")
+	}
+	if len(orgs) > maxKeyEndorsementOrgs {
+		return nil, fmt.Errorf("the approval policy names %d organizations, at most %d are supported", len(orgs), maxKeyEndorsementOrgs)
+	}
+
+	// Give every present organization one signer per leaf, enough for any Signers quorum
+	signersPerOrg := countLeaves(approvalPolicy)
+	var minimal []uint32
+	for set := uint32(1); set < 1<<len(orgs); set++ {
+		var signers []string
+		for i, org := range orgs {
+			if set&(1<<i) != 0 {
+				for j := 0; j < signersPerOrg; j++ {
+					signers = append(signers, org)
+				}
+			}
+		}
+		if !approvalPolicy.Evaluate(signers) {
+			continue
+		}
+
+		// Sets are visited in increasing order, so any subset that suffices was seen first
+		redundant := false
+		for _, smaller := range minimal {
+			if set&smaller == smaller {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			minimal = append(minimal, set)
+		}
+	}
+	if len(minimal) == 0 {
+		return nil, fmt.Errorf("the approval policy cannot be satisfied")
+	}
+
+	// Only organizations that appear in a sufficient set endorse the key
+	var used uint32
+	for _, set := range minimal {
+		used |= set
+	}
+	var identities []*msp.MSPPrincipal
+	signedBy := map[int]*common.SignaturePolicy{}
+	for i, org := range orgs {
+		if used&(1<<i) == 0 {
+			continue
+		}
+		principal, err := proto.Marshal(&msp.MSPRole{MspIdentifier: org, Role: msp.MSPRole_PEER})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal principal %s: %v", org, err)
+		}
+		signedBy[i] = &common.SignaturePolicy{
+			Type: &common.SignaturePolicy_SignedBy{SignedBy: int32(len(identities))},
+		}
+		identities = append(identities, &msp.MSPPrincipal{
+			PrincipalClassification: msp.MSPPrincipal_ROLE,
+			Principal:               principal,
+		})
+	}
+
+	rulesOf := func(set uint32) []*common.SignaturePolicy {
+		var rules []*common.SignaturePolicy
+		for i := range orgs {
+			if set&(1<<i) != 0 {
+				rules = append(rules, signedBy[i])
+			}
+		}
+		return rules
+	}
+
+	// When the sufficient sets are every n-organization combination the policy is OutOf(n), which
+	// also covers ANY and ALL; anything else is an OR of the sets
+	var rule *common.SignaturePolicy
+	if n, ok := outOfThreshold(minimal, len(identities)); ok {
+		rule = nOutOf(n, rulesOf(used))
+	} else {
+		sets := make([]*common.SignaturePolicy, len(minimal))
+		for i, set := range minimal {
+			setRules := rulesOf(set)
+			sets[i] = nOutOf(len(setRules), setRules)
+		}
+		rule = nOutOf(1, sets)
+	}
+
+	return &common.SignaturePolicyEnvelope{Rule: rule, Identities: identities}, nil
+}
+
+// Returns n if the sets are all n-element subsets of the organizations
+func outOfThreshold(sets []uint32, orgs int) (int, bool) {
+	n := bitCount(sets[0])
+	for _, set := range sets[1:] {
+		if bitCount(set) != n {
+			return 0, false
+		}
+	}
+
+	combinations := 1
+	for i := 0; i < n; i++ {
+		combinations = combinations * (orgs - i) / (i + 1)
+	}
+	return n, len(sets) == combinations
+}
+
+func bitCount(set uint32) int {
+	count := 0
+	for ; set != 0; set &= set - 1 {
+		count++
+	}
+	return count
+}
+
+func countLeaves(p *policy.Policy) int {
+	if p.Rules == nil {
+		return 1
+	}
+	count := 0
+	for _, rule := range p.Rules {
+		count += countLeaves(rule)
+	}
+	return count
+}
+
+func nOutOf(n int, rules []*common.SignaturePolicy) *common.SignaturePolicy {
+	return &common.SignaturePolicy{
+		Type: &common.SignaturePolicy_NOutOf_{
+			NOutOf: &common.SignaturePolicy_NOutOf{N: int32(n), Rules: rules},
+		},
+	}
+}
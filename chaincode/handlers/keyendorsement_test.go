@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models/policy"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// Returns the key-level endorsement policy of a key in the approval policy syntax
+func keyEndorsers(t *testing.T, p *peer, key string) string {
+	t.Helper()
+
+	policyBytes := p.stub.EndorsementPolicies[""][key]
+	if policyBytes == nil {
+		t.Fatalf("key %s has no endorsement policy", key)
+	}
+	var envelope common.SignaturePolicyEnvelope
+	if err := proto.Unmarshal(policyBytes, &envelope); err != nil {
+		t.Fatalf("failed to parse endorsement policy of %s: %v", key, err)
+	}
+
+	var orgs []string
+	for _, identity := range envelope.Identities {
+		var role msp.MSPRole
+		if err := proto.Unmarshal(identity.Principal, &role); err != nil {
+			t.Fatalf("failed to parse principal in endorsement policy of %s: %v", key, err)
+		}
+		if role.Role != msp.MSPRole_PEER {
+			t.Fatalf("endorsement policy of %s names %s role %v, want peers", key, role.MspIdentifier, role.Role)
+		}
+		orgs = append(orgs, role.MspIdentifier)
+	}
+
+	var convert func(rule *common.SignaturePolicy) *policy.Policy
+	convert = func(rule *common.SignaturePolicy) *policy.Policy {
+		if outOf := rule.GetNOutOf(); outOf != nil {
+			if len(outOf.Rules) == 1 {
+				return convert(outOf.Rules[0])
+			}
+			converted := &policy.Policy{N: int(outOf.N)}
+			for _, sub := range outOf.Rules {
+				converted.Rules = append(converted.Rules, convert(sub))
+			}
+			return converted
+		}
+		return &policy.Policy{Principal: orgs[rule.GetSignedBy()]}
+	}
+	return convert(envelope.Rule).String()
+}
+
+func TestRegisterSetsKeyEndorsementPolicy(t *testing.T) {
+	org1User := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	org2User := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=user1::CN=ca.org2"}
+	start := time.Date(2025, 2, 3, 10, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "report.pdf", "QmHash1", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org2MSP","Org1MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+		return err
+	})
+	if got, want := keyEndorsers(t, p, "file1"), "AND('Org1MSP', 'Org2MSP')"; got != want {
+		t.Fatalf("file1 endorsers = %v, want %v", got, want)
+	}
+
+	// Workflow files hand the key over to each stage's organizations as they advance
+	p.endorse(t, "tx2", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		return CreateWorkflow(ctx, "two-step", "Two step", `[
+			{"name": "review", "policy": "'Org2MSP'"},
+			{"name": "sign-off", "policy": "'Org1MSP'"}
+		]`)
+	})
+	p.endorse(t, "tx3", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file2", "plan.pdf", "QmHash2", "Org1", `{}`, "",
+			`{"policyType":"WORKFLOW","workflow":"two-step"}`, "", "", "")
+		return err
+	})
+	if got, want := keyEndorsers(t, p, "file2"), "'Org2MSP'"; got != want {
+		t.Fatalf("file2 endorsers in the first stage = %v, want %v", got, want)
+	}
+
+	p.endorse(t, "tx4", start.Add(time.Hour), org2User, func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "file2")
+	})
+	if got, want := keyEndorsers(t, p, "file2"), "'Org1MSP'"; got != want {
+		t.Fatalf("file2 endorsers in the second stage = %v, want %v", got, want)
+	}
+}
+
+func TestKeyEndorsementFollowsTheApprovalPolicy(t *testing.T) {
+	org1User := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	start := time.Date(2025, 2, 3, 10, 0, 0, 0, time.UTC)
+
+	// The key policy counts organizations whose peers must endorse, not signers
+	cases := []struct {
+		config string
+		want   string
+	}{
+		{`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ANY_ORG"}`, "OR('Org1MSP', 'Org2MSP')"},
+		{`{"policyType":"CUSTOM","policy":"OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')"}`, "OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')"},
+		{`{"policyType":"CUSTOM","policy":"Signers(2, 'Org1MSP')"}`, "'Org1MSP'"},
+		{`{"policyType":"CUSTOM","policy":"AND(Signers(2, 'Org1MSP'), 'Org2MSP')"}`, "AND('Org1MSP', 'Org2MSP')"},
+		{`{"policyType":"CUSTOM","policy":"OR('Org1MSP', AND('Org2MSP', 'Org3MSP'))"}`, "OR('Org1MSP', AND('Org2MSP', 'Org3MSP'))"},
+	}
+
+	p := newPeer("peer0.org1")
+	for i, c := range cases {
+		fileID := "file" + string(rune('1'+i))
+		p.endorse(t, "tx-"+fileID, start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, fileID, "report.pdf", "QmHash"+fileID, "Org1", `{}`, "", c.config, "", "", "")
+			return err
+		})
+		if got := keyEndorsers(t, p, fileID); got != c.want {
+			t.Errorf("%s endorsers = %s, want %s", c.config, got, c.want)
+		}
+	}
+
+	// Organizations that never joined have no peers to endorse with and would lock the file
+	err := p.invoke("tx-outsider", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file9", "report.pdf", "QmHash9", "Org1", `{}`, "",
+			`{"policyType":"CUSTOM","policy":"OutOf(2, 'Org1MSP', 'Org2MSP', 'Org9MSP')"}`, "", "", "")
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "Org9MSP is not a registered member") {
+		t.Fatalf("registering with an unknown organization returned %v", err)
+	}
+	err = p.invoke("tx-workflow", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		return CreateWorkflow(ctx, "outsiders", "Outsiders", `[{"name": "review", "policy": "'Org9MSP'"}]`)
+	})
+	if err == nil || !strings.Contains(err.Error(), "Org9MSP is not a registered member") {
+		t.Fatalf("creating a workflow with an unknown organization returned %v", err)
+	}
+
+	// Only an admin of the organization itself can register it
+	org9User := &fakeIdentity{mspID: "Org9MSP", id: "x509::CN=user1::CN=ca.org9", attrs: map[string]string{"hf.Type": "client"}}
+	if err := p.invoke("tx-join", start, org9User, RegisterOrganization); err == nil {
+		t.Fatal("a non-admin registered its organization")
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Tombstone states. Live files have no tombstone.
+const (
+	StateArchived = "ARCHIVED"
+	StateDeleted  = "DELETED"
+)
+
+// Refuses changes to archived and deleted files, which must be restored first
+func requireLive(file *models.File) error {
+	if file.Tombstone != nil {
+		return fmt.Errorf("file %s is %s, restore it first", file.ID, file.Tombstone.State)
+	}
+	return nil
+}
+
+// Archives a file, or every version of its chain, hiding it from default queries
+func ArchiveFile(ctx contractapi.TransactionContextInterface, id string, reason string, wholeChain bool) error {
+	archived, err := retireFiles(ctx, id, reason, wholeChain, StateArchived)
+	if err != nil {
+		return err
+	}
+	return emitFileEvent(ctx, models.EventFileArchived, archived[0], archived[1:]...)
+}
+
+// Deletes a file, or every version of its chain. The records stay on the ledger as tombstones.
+// Returns a DeletionReport as JSON so the caller can unpin content nothing live refers to any more.
+func DeleteFile(ctx contractapi.TransactionContextInterface, id string, reason string, wholeChain bool) (string, error) {
+	deleted, err := retireFiles(ctx, id, reason, wholeChain, StateDeleted)
+	if err != nil {
+		return "", err
+	}
+
+	report := models.DeletionReport{Deleted: []string{}, Unreferenced: []string{}}
+	deletedIDs := map[string]bool{}
+	for _, file := range deleted {
+		report.Deleted = append(report.Deleted, file.ID)
+		deletedIDs[file.ID] = true
+	}
+
+	checked := map[string]bool{}
+	for _, file := range deleted {
+		if checked[file.Hash] {
+			continue
+		}
+		checked[file.Hash] = true
+
+		referenced, err := contentReferenced(ctx, file.Hash, deletedIDs)
+		if err != nil {
+			return "", err
+		}
+		if referenced {
+			continue
+		}
+
+		// Content held for another file, even a deleted one, must stay pinned
+		held, err := IsContentHeld(ctx, file.Hash)
+		if err != nil {
+			return "", err
+		}
+		if !held {
+			report.Unreferenced = append(report.Unreferenced, file.IPFSLocation)
+		}
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal deletion report: %v", err)
+	}
+
+	if err := emitFileEvent(ctx, models.EventFileDeleted, deleted[0], deleted[1:]...); err != nil {
+		return "", err
+	}
+
+	return string(reportJSON), nil
+}
+
+// Brings back an archived or deleted file, or every retired version of its chain
+func RestoreFile(ctx contractapi.TransactionContextInterface, id string, wholeChain bool) error {
+	files, err := lifecycleTargets(ctx, id, wholeChain)
+	if err != nil {
+		return err
+	}
+
+	var restored []*models.File
+	for _, file := range files {
+		if file.Tombstone == nil {
+			continue
+		}
+
+		previousState := file.Tombstone.State
+		file.Tombstone = nil
+		if err := putFile(ctx, file); err != nil {
+			return err
+		}
+		restored = append(restored, file)
+
+		details := fmt.Sprintf("File %s restored from %s", file.Name, previousState)
+		if err := CreateAuditLog(ctx, file.ID, "RESTORE", details); err != nil {
+			return err
+		}
+	}
+
+	if len(restored) == 0 {
+		return fmt.Errorf("file %s is not archived or deleted", id)
+	}
+	return emitFileEvent(ctx, models.EventFileRestored, restored[0], restored[1:]...)
+}
+
+// Puts a tombstone in the given state on the targeted files, returning the files it changed
+func retireFiles(ctx contractapi.TransactionContextInterface, id string, reason string, wholeChain bool, state string) ([]*models.File, error) {
+	action, verb := "ARCHIVE", "archive"
+	if state == StateDeleted {
+		action, verb = "DELETE", "delete"
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to %s a file", verb)
+	}
+
+	files, err := lifecycleTargets(ctx, id, wholeChain)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client ID: %v", err)
+	}
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []*models.File
+	for _, file := range files {
+		// Deleted files stay deleted, and archiving an archived file changes nothing
+		if file.Tombstone != nil && (file.Tombstone.State == state || file.Tombstone.State == StateDeleted) {
+			continue
+		}
+		if err := requireDisposable(file, txTime); err != nil {
+			return nil, err
+		}
+
+		file.Tombstone = &models.Tombstone{
+			State:     state,
+			Reason:    reason,
+			MSPID:     mspID,
+			ClientID:  clientID,
+			Timestamp: txTime.Format(time.RFC3339),
+		}
+		if err := putFile(ctx, file); err != nil {
+			return nil, err
+		}
+		changed = append(changed, file)
+
+		details := fmt.Sprintf("File %s %s by %s: %s", file.Name, state, mspID, reason)
+		if err := CreateAuditLog(ctx, file.ID, action, details); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil, fmt.Errorf("file %s is already %s", id, files[0].Tombstone.State)
+	}
+	return changed, nil
+}
+
+// Returns the file, or every version of its chain, after checking the caller owns all of them
+func lifecycleTargets(ctx contractapi.TransactionContextInterface, id string, wholeChain bool) ([]*models.File, error) {
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("file does not exist: %s", id)
+	}
+
+	files := []*models.File{file}
+	if wholeChain {
+		if files, err = chainFiles(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, target := range files {
+		if target.OwnerMSP == "" {
+			return nil, fmt.Errorf("file %s predates ownership records and has no owner", target.ID)
+		}
+		owner, err := isOwner(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		if !owner {
+			return nil, fmt.Errorf("only the owner of file %s can archive, delete or restore it", target.ID)
+		}
+	}
+
+	return files, nil
+}
+
+// Returns every version of the chain a file belongs to, oldest first along each branch
+func chainFiles(ctx contractapi.TransactionContextInterface, file *models.File) ([]*models.File, error) {
+	// Walk back to the first version
+	root := file
+	for root.PreviousID != "" {
+		previous, err := readFile(ctx, root.PreviousID)
+		if err != nil {
+			return nil, err
+		}
+		if previous == nil {
+			break
+		}
+		root = previous
+	}
+
+	// Then forward through every successor
+	files := []*models.File{}
+	visited := map[string]bool{root.ID: true}
+	queue := []*models.File{root}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		files = append(files, current)
+
+		successorIDs, err := getSuccessorIDs(ctx, current.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, successorID := range successorIDs {
+			if visited[successorID] {
+				continue
+			}
+			visited[successorID] = true
+
+			successor, err := readFile(ctx, successorID)
+			if err != nil {
+				return nil, err
+			}
+			if successor != nil {
+				queue = append(queue, successor)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// Reports whether any file that is not deleted still refers to the content.
+// Archived files count, they can be restored at any time. Reads do not see this
+// transaction's own writes, so the files it just deleted are passed in.
+func contentReferenced(ctx contractapi.TransactionContextInterface, hash string, deletedIDs map[string]bool) (bool, error) {
+	ids, err := getFileIDsByHash(ctx, hash)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range ids {
+		if deletedIDs[id] {
+			continue
+		}
+		file, err := readFile(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if file != nil && (file.Tombstone == nil || file.Tombstone.State != StateDeleted) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestDeletedFilesAreHiddenAndRestorable(t *testing.T) {
+	owner := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=owner::CN=ca.org1"}
+	other := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=user1::CN=ca.org2"}
+	start := time.Date(2025, 10, 6, 9, 0, 0, 0, time.UTC)
+
+	register := func(id string, hash string, previousID string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, id+".pdf", hash, "Org1", `{}`, previousID,
+				`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ANY_ORG"}`, "", "", "")
+			return err
+		}
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, owner, register("v1", "QmShared", ""))
+	p.endorse(t, "tx2", start, owner, register("v2", "QmOnlyV2", "v1"))
+	p.endorse(t, "tx3", start, owner, register("copy", "QmShared", ""))
+
+	// The mock stub cannot paginate, so the default listing is checked through QueryAllFiles
+	listed := func(txID string) map[string]bool {
+		var files []models.File
+		p.endorse(t, txID, start, owner, func(ctx contractapi.TransactionContextInterface) error {
+			filesJSON, err := QueryAllFiles(ctx)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(filesJSON), &files)
+		})
+		ids := map[string]bool{}
+		for _, file := range files {
+			ids[file.ID] = true
+		}
+		return ids
+	}
+
+	if err := p.invoke("tx4", start, other, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := DeleteFile(ctx, "v1", "cleanup", true)
+		return err
+	}); err == nil {
+		t.Fatal("a user who does not own the chain deleted it")
+	}
+
+	var report models.DeletionReport
+	p.endorse(t, "tx5", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		reportJSON, err := DeleteFile(ctx, "v2", "superseded draft", true)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(reportJSON), &report)
+	})
+
+	// The whole chain is gone, but only v2's content is free; copy still uses v1's
+	if len(report.Deleted) != 2 {
+		t.Fatalf("deleted %v, want both versions", report.Deleted)
+	}
+	if len(report.Unreferenced) != 1 || report.Unreferenced[0] != "QmOnlyV2" {
+		t.Fatalf("unreferenced content %v, want [QmOnlyV2]", report.Unreferenced)
+	}
+
+	if ids := listed("tx6"); ids["v1"] || ids["v2"] || !ids["copy"] {
+		t.Fatalf("default query lists %v, want only copy", ids)
+	}
+	deletedOnly := models.FileFilter{Lifecycle: StateDeleted}
+	if !deletedOnly.Matches(*p.file(t, "v2")) || deletedOnly.Matches(*p.file(t, "copy")) {
+		t.Fatal("the DELETED lifecycle filter does not select exactly the deleted files")
+	}
+
+	// Deleted files take no further changes
+	if err := p.invoke("tx8", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		return UpdateReaders(ctx, "v2", `[{"mspId":"Org2MSP"}]`)
+	}); err == nil {
+		t.Fatal("changed the readers of a deleted file")
+	}
+
+	p.endorse(t, "tx9", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		return RestoreFile(ctx, "v1", false)
+	})
+	if got := p.file(t, "v1"); got.Tombstone != nil {
+		t.Fatalf("v1 still has tombstone %+v after restore", got.Tombstone)
+	}
+	if got := p.file(t, "v2"); got.Tombstone == nil || got.Tombstone.Reason != "superseded draft" {
+		t.Fatalf("v2 tombstone = %+v, want it kept with its reason", got.Tombstone)
+	}
+}
+
+func TestDeletionReportsContentNoFileRefersTo(t *testing.T) {
+	owner := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=owner::CN=ca.org1"}
+	start := time.Date(2025, 10, 6, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	for _, id := range []string{"file1", "file2"} {
+		p.endorse(t, "register-"+id, start, owner, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, id+".pdf", "QmShared", "Org1", `{}`, "",
+				`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, "", "", "")
+			return err
+		})
+	}
+
+	deleteFile := func(txID string, id string) []string {
+		var report models.DeletionReport
+		p.endorse(t, txID, start, owner, func(ctx contractapi.TransactionContextInterface) error {
+			reportJSON, err := DeleteFile(ctx, id, "cleanup", false)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(reportJSON), &report)
+		})
+		return report.Unreferenced
+	}
+
+	// Deleting one of the files leaves the content in use
+	if unreferenced := deleteFile("tx1", "file1"); len(unreferenced) != 0 {
+		t.Fatalf("unreferenced content %v while file2 uses it, want none", unreferenced)
+	}
+	if unreferenced := deleteFile("tx2", "file2"); len(unreferenced) != 1 || unreferenced[0] != "QmShared" {
+		t.Fatalf("unreferenced content %v once no file uses it, want [QmShared]", unreferenced)
+	}
+}
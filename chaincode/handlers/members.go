@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefix for the organizations that joined the file manager
+const channelMemberPrefix = "member"
+
+// Records the caller's organization as a channel member. Only admins of that organization may
+// do this; the transaction is signed by the organization's MSP, which the channel only accepts
+// from its members.
+func RegisterOrganization(ctx contractapi.TransactionContextInterface) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	admin, err := callerIsAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("only admins of %s can register it", mspID)
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	memberJSON, err := json.Marshal(models.ChannelMember{
+		MSPID:        mspID,
+		RegisteredBy: clientID,
+		Timestamp:    txTime.Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel member: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(channelMemberPrefix, []string{mspID})
+	if err != nil {
+		return fmt.Errorf("failed to create channel member key: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(key, memberJSON); err != nil {
+		return fmt.Errorf("failed to save channel member: %v", err)
+	}
+
+	return nil
+}
+
+// Fails unless every organization has been registered as a channel member.
+// An organization outside the channel has no peers to endorse its part of a file's key
+// endorsement policy, so naming one could lock the file.
+func requireChannelMembers(ctx contractapi.TransactionContextInterface, orgs []string) error {
+	for _, mspID := range orgs {
+		key, err := ctx.GetStub().CreateCompositeKey(channelMemberPrefix, []string{mspID})
+		if err != nil {
+			return fmt.Errorf("failed to create channel member key: %v", err)
+		}
+
+		memberJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read channel member %s: %v", mspID, err)
+		}
+		if memberJSON == nil {
+			return fmt.Errorf("organization %s is not a registered member of the channel", mspID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRegisterOrganizationNeedsAnAdmin(t *testing.T) {
+	start := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	p := newPeer("peer0.org1")
+
+	// cryptogen issues no attributes, its admins are recognized by their node OU
+	for i, identity := range []*fakeIdentity{
+		{mspID: "Org4MSP", id: "x509::CN=Admin@org4.example.com::CN=ca.org4", ous: []string{"admin"}},
+		{mspID: "Org5MSP", id: "x509::CN=org5admin::CN=ca.org5", attrs: map[string]string{"hf.Type": "admin"}},
+	} {
+		if err := p.invoke("tx-admin-"+identity.mspID, start.Add(time.Duration(i)*time.Minute), identity, RegisterOrganization); err != nil {
+			t.Fatalf("admin of %s could not register it: %v", identity.mspID, err)
+		}
+	}
+
+	for _, identity := range []*fakeIdentity{
+		{mspID: "Org6MSP", id: "x509::CN=User1@org6.example.com::CN=ca.org6", ous: []string{"client"}},
+		{mspID: "Org6MSP", id: "x509::CN=user1::CN=ca.org6", attrs: map[string]string{"hf.Type": "client"}},
+	} {
+		if err := p.invoke("tx-client-"+identity.id, start, identity, RegisterOrganization); err == nil {
+			t.Fatalf("client %s registered its organization", identity.id)
+		}
+	}
+
+	p.endorse(t, "tx-check", start, &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}, func(ctx contractapi.TransactionContextInterface) error {
+		if err := requireChannelMembers(ctx, []string{"Org4MSP", "Org5MSP"}); err != nil {
+			t.Fatalf("registered organizations are not members: %v", err)
+		}
+		if err := requireChannelMembers(ctx, []string{"Org6MSP"}); err == nil {
+			t.Fatal("an organization no admin registered is a member")
+		}
+		return nil
+	})
+}
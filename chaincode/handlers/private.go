@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Transient map keys carrying the confidential part of a private registration.
+// Transient data is not written to the transaction, so it never reaches the public ledger.
+const (
+	transientMetadataKey = "metadata"
+	transientOwnerKey    = "owner"
+)
+
+// Collections in collections_config.json are named after the organizations sharing them,
+// e.g. filePrivate_Org1MSP_Org2MSP; MSP IDs are sorted and joined with underscores.
+const privateCollectionPrefix = "filePrivate_"
+
+// Returns the private data collection shared by exactly the given organizations
+func privateCollectionName(orgs []string) string {
+	return privateCollectionPrefix + strings.Join(uniqueOrgs(orgs), "_")
+}
+
+// Reads one value of a private registration from the transient map
+func transientValue(ctx contractapi.TransactionContextInterface, key string) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transient data: %v", err)
+	}
+
+	value, ok := transient[key]
+	if !ok || len(value) == 0 {
+		return "", fmt.Errorf("private registrations must pass their %s as transient data under %q", key, key)
+	}
+
+	return string(value), nil
+}
+
+// Writes the private details of a file to the collection and returns their hash for the public record
+func putPrivateDetails(ctx contractapi.TransactionContextInterface, collection string, details models.FilePrivateDetails) (string, error) {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal private details: %v", err)
+	}
+
+	if err := ctx.GetStub().PutPrivateData(collection, details.ID, detailsJSON); err != nil {
+		return "", fmt.Errorf("failed to store private details in collection %s: %v", collection, err)
+	}
+
+	hash := sha256.Sum256(detailsJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Returns the private details of a file as JSON. Only peers of the organizations sharing
+// the file's collection hold the data, so other organizations get an error.
+func GetFilePrivateDetails(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if file == nil {
+		return "", fmt.Errorf("file does not exist: %s", id)
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !caller.canRead(file) {
+		return "", fmt.Errorf("access denied: organization %s cannot read file %s", caller.mspID, id)
+	}
+	if file.PrivateCollection == "" {
+		return "", fmt.Errorf("file %s has no private details", id)
+	}
+
+	detailsJSON, err := readPrivateDetails(ctx, file)
+	if err != nil {
+		return "", err
+	}
+
+	return string(detailsJSON), nil
+}
+
+// Reads the private details of a file from its collection and checks them against the hash on
+// the public record, so tampered private data is caught
+func readPrivateDetails(ctx contractapi.TransactionContextInterface, file *models.File) ([]byte, error) {
+	detailsJSON, err := ctx.GetStub().GetPrivateData(file.PrivateCollection, file.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private details from collection %s: %v", file.PrivateCollection, err)
+	}
+	if detailsJSON == nil {
+		return nil, fmt.Errorf("private details of file %s are not available to this organization", file.ID)
+	}
+
+	hash := sha256.Sum256(detailsJSON)
+	if hex.EncodeToString(hash[:]) != file.PrivateDataHash {
+		return nil, fmt.Errorf("private details of file %s do not match the hash on the ledger", file.ID)
+	}
+	return detailsJSON, nil
+}
+
+// Replaces the owner name kept in the private details of a file and records their new hash on
+// the file. Only peers of the organizations sharing the collection can do this.
+func setPrivateOwner(ctx contractapi.TransactionContextInterface, file *models.File, owner string) error {
+	detailsJSON, err := readPrivateDetails(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	var details models.FilePrivateDetails
+	if err := json.Unmarshal(detailsJSON, &details); err != nil {
+		return fmt.Errorf("failed to unmarshal private details of file %s: %v", file.ID, err)
+	}
+	details.Owner = owner
+
+	file.PrivateDataHash, err = putPrivateDetails(ctx, file.PrivateCollection, details)
+	return err
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestPrivateMetadataStaysOffThePublicLedger(t *testing.T) {
+	org1User := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	start := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	secret := `{"size":42,"type":"application/pdf","client":"Acme Corp"}`
+
+	register := func(owner string, metadata string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, "file1", "nda.pdf", "QmHash", owner, metadata, "",
+				`{"requiredOrgs":["Org2MSP","Org1MSP"],"policyType":"ALL_ORGS","private":true}`, "", "", "")
+			return err
+		}
+	}
+
+	p := newPeer("peer0.org1")
+
+	// The owner name and metadata must not be passed as plain arguments, nor left out
+	if err := p.invoke("tx1", start, org1User, register("", secret)); err == nil {
+		t.Fatal("private registration with metadata as an argument succeeded")
+	}
+	p.stub.TransientMap = map[string][]byte{transientMetadataKey: []byte(secret)}
+	if err := p.invoke("tx1b", start, org1User, register("Acme Legal", "")); err == nil {
+		t.Fatal("private registration with the owner as an argument succeeded")
+	}
+	if err := p.invoke("tx2", start, org1User, register("", "")); err == nil {
+		t.Fatal("private registration without a transient owner succeeded")
+	}
+
+	p.stub.TransientMap[transientOwnerKey] = []byte("Acme Legal")
+	p.endorse(t, "tx3", start, org1User, register("", ""))
+	p.stub.TransientMap = nil
+
+	for key, value := range p.stub.State {
+		if strings.Contains(string(value), "Acme Corp") || strings.Contains(string(value), "Acme Legal") {
+			t.Fatalf("public state key %q contains the private owner or metadata", key)
+		}
+	}
+
+	file := p.file(t, "file1")
+	if file.PrivateCollection != "filePrivate_Org1MSP_Org2MSP" || file.PrivateDataHash == "" {
+		t.Fatalf("private collection %q with hash %q, want filePrivate_Org1MSP_Org2MSP and a hash", file.PrivateCollection, file.PrivateDataHash)
+	}
+	if file.Owner != "" || file.Metadata != "" || file.MimeType != "" {
+		t.Fatalf("public record leaks owner %q, metadata %q, MIME type %q", file.Owner, file.Metadata, file.MimeType)
+	}
+	if file.OwnerMSP != "Org1MSP" {
+		t.Fatalf("owner MSP = %q, want Org1MSP kept public for access checks", file.OwnerMSP)
+	}
+
+	var details models.FilePrivateDetails
+	p.endorse(t, "tx4", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		detailsJSON, err := GetFilePrivateDetails(ctx, "file1")
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(detailsJSON), &details)
+	})
+	if details.Owner != "Acme Legal" {
+		t.Fatalf("private owner = %q, want Acme Legal", details.Owner)
+	}
+	// The metadata is kept at the current schema version, its extra field as a custom value
+	metadata, err := models.ParseMetadata(details.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.SchemaVersion != models.MetadataSchemaVersion || metadata.Type != "application/pdf" || metadata.Custom["client"] != "Acme Corp" {
+		t.Fatalf("private metadata = %q, want %q upgraded to the current schema", details.Metadata, secret)
+	}
+
+	// Private data that no longer matches the public hash is refused
+	p.stub.PvtState[file.PrivateCollection]["file1"] = []byte(`{"id":"file1","metadata":"{}"}`)
+	if err := p.invoke("tx5", start, org1User, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := GetFilePrivateDetails(ctx, "file1")
+		return err
+	}); err == nil {
+		t.Fatal("tampered private details were returned")
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"dltfm/pkg/models"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -19,6 +22,11 @@ func QueryAllFiles(ctx contractapi.TransactionContextInterface) (string, error)
 	}
 	defer resultsIterator.Close()
 
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	var files []models.File
 	for resultsIterator.HasNext() {
 		response, err := resultsIterator.Next()
@@ -34,7 +42,10 @@ func QueryAllFiles(ctx contractapi.TransactionContextInterface) (string, error)
 			continue // Skip invalid entries instead of failing
 		}
 
-		files = append(files, file)
+		// Archived and deleted files only show up when filtered for, see QueryFilesPage
+		if file.Tombstone == nil && caller.canRead(&file) {
+			files = append(files, file)
+		}
 	}
 
 	if len(files) == 0 {
@@ -49,7 +60,165 @@ func QueryAllFiles(ctx contractapi.TransactionContextInterface) (string, error)
 	return string(filesJSON), nil
 }
 
-// Retrieve a file by ID
+// Query one page of files in the ledger, optionally filtered.
+// Records are read until pageSize files match or the ledger runs out, so only the last page
+// is short and an empty bookmark means there is nothing more. A filter that matches few files
+// can read many records to fill one page.
+func QueryFilesPage(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string, filter string) (string, error) {
+	if pageSize <= 0 {
+		return "", fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	var fileFilter models.FileFilter
+	if filter != "" {
+		if err := json.Unmarshal([]byte(filter), &fileFilter); err != nil {
+			return "", fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	page := models.FilePage{Files: []models.File{}}
+	for {
+		// Never fetch more than the page has room for, so the bookmark stays exact
+		fetched, next, err := scanFiles(ctx, pageSize-int32(len(page.Files)), bookmark, func(key string, file models.File) (*models.File, error) {
+			if !fileFilter.Matches(file) || !caller.canRead(&file) {
+				return nil, nil
+			}
+			return &file, nil
+		}, &page)
+		if err != nil {
+			return "", err
+		}
+		page.FetchedRecordsCount += fetched
+		bookmark = next
+
+		if bookmark == "" || int32(len(page.Files)) == pageSize {
+			break
+		}
+	}
+	page.Bookmark = bookmark
+
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal files page: %v", err)
+	}
+
+	return string(pageJSON), nil
+}
+
+// Reads up to limit records from bookmark, adding the file keep returns for each record to
+// the page, if any. Returns how many records were read and the bookmark of the next one.
+func scanFiles(ctx contractapi.TransactionContextInterface, limit int32, bookmark string, keep func(key string, file models.File) (*models.File, error), page *models.FilePage) (int32, string, error) {
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByRangeWithPagination("", "", limit, bookmark)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get state range: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to iterate state: %v", err)
+		}
+
+		var file models.File
+		if err := json.Unmarshal(response.Value, &file); err != nil {
+			fmt.Printf("ERROR: Failed to unmarshal file: %v\n", err)
+			continue // Skip invalid entries instead of failing
+		}
+
+		kept, err := keep(response.Key, file)
+		if err != nil {
+			return 0, "", err
+		}
+		if kept != nil {
+			page.Files = append(page.Files, *kept)
+		}
+	}
+
+	if metadata == nil {
+		return 0, "", nil
+	}
+	return metadata.FetchedRecordsCount, metadata.Bookmark, nil
+}
+
+// Query files with a CouchDB Mango selector, one page at a time.
+// Only works when the peers use CouchDB as their state database.
+func QueryFiles(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int32, bookmark string) (string, error) {
+	if pageSize <= 0 {
+		return "", fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	var selector map[string]interface{}
+	if err := json.Unmarshal([]byte(selectorJSON), &selector); err != nil {
+		return "", fmt.Errorf("invalid selector: %v", err)
+	}
+	if err := models.ValidateSelector(selector); err != nil {
+		return "", fmt.Errorf("invalid selector: %v", err)
+	}
+
+	// Archived and deleted files are left out unless the selector asks about them anywhere
+	if !models.SelectorReferences(selector, "tombstone.state") {
+		selector["tombstone"] = map[string]interface{}{"$exists": false}
+	}
+
+	queryJSON, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal query: %v", err)
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryJSON), pageSize, bookmark)
+	if err != nil {
+		return "", fmt.Errorf("failed to run rich query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	page := models.FilePage{Files: []models.File{}}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return "", fmt.Errorf("failed to iterate query results: %v", err)
+		}
+
+		// Composite keys (audit logs, indexes) start with a null byte and are never files
+		if strings.HasPrefix(response.Key, "\x00") {
+			continue
+		}
+
+		var file models.File
+		if err := json.Unmarshal(response.Value, &file); err != nil {
+			fmt.Printf("ERROR: Failed to unmarshal file: %v\n", err)
+			continue // Skip invalid entries instead of failing
+		}
+
+		if caller.canRead(&file) {
+			page.Files = append(page.Files, file)
+		}
+	}
+
+	if metadata != nil {
+		page.Bookmark = metadata.Bookmark
+		page.FetchedRecordsCount = metadata.FetchedRecordsCount
+	}
+
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal files page: %v", err)
+	}
+
+	return string(pageJSON), nil
+}
+
+// Retrieve a file by ID, provided the caller may read it
 func GetFileByID(ctx contractapi.TransactionContextInterface, id string) (string, error) {
 	fileJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
@@ -58,30 +227,46 @@ func GetFileByID(ctx contractapi.TransactionContextInterface, id string) (string
 	if fileJSON == nil {
 		return "", nil // Instead of returning an error, return nil to indicate the file wasn't found
 	}
+
+	var file models.File
+	if err := json.Unmarshal(fileJSON, &file); err != nil {
+		return "", fmt.Errorf("error unmarshaling file %s: %v", id, err)
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !caller.canRead(&file) {
+		return "", fmt.Errorf("access denied: organization %s cannot read file %s", caller.mspID, id)
+	}
+
 	return string(fileJSON), nil
 }
 
-// Retrieve all versions of a file
+// Retrieve all versions of a file. Versions the caller cannot read are left out,
+// but the walk continues through them to older versions.
 func GetFileVersions(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	var versions []models.File
 	currentID := id
 
 	for currentID != "" {
-		fileJSON, err := GetFileByID(ctx, currentID)
+		file, err := readFile(ctx, currentID)
 		if err != nil {
 			return "", fmt.Errorf("failed to fetch version history: %v", err)
 		}
-		if fileJSON == "" {
+		if file == nil {
 			break // Stop if there is no previous version
 		}
 
-		var file models.File
-		err = json.Unmarshal([]byte(fileJSON), &file)
-		if err != nil {
-			return "", fmt.Errorf("error unmarshaling file: %v", err)
+		if caller.canRead(file) {
+			versions = append(versions, *file)
 		}
-
-		versions = append(versions, file)
 		currentID = file.PreviousID // Move to the previous version
 	}
 
@@ -93,35 +278,43 @@ func GetFileVersions(ctx contractapi.TransactionContextInterface, id string) (st
 	return string(filesJSON), nil
 }
 
-// Retrieve a file by its hash value
+// Retrieve a file by its hash value through the hash index.
+// When the content is registered more than once the first indexed file the caller can read is returned.
 func GetFileByHash(ctx contractapi.TransactionContextInterface, hash string) (string, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	ids, err := getFileIDsByHash(ctx, hash)
 	if err != nil {
-		return "", fmt.Errorf("failed to get state range: %v", err)
+		return "", err
 	}
-	defer resultsIterator.Close()
 
-	for resultsIterator.HasNext() {
-		response, err := resultsIterator.Next()
-		if err != nil {
-			return "", fmt.Errorf("failed to iterate state: %v", err)
-		}
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
 
-		var file models.File
-		err = json.Unmarshal(response.Value, &file)
+	for _, id := range ids {
+		file, err := readFile(ctx, id)
 		if err != nil {
-			continue // Skip invalid entries instead of failing
+			return "", err
+		}
+		if file == nil || file.Tombstone != nil || !caller.canRead(file) {
+			continue
 		}
 
-		if file.Hash == hash {
-			return string(response.Value), nil
+		fileJSON, err := json.Marshal(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal file: %v", err)
 		}
+		return string(fileJSON), nil
 	}
 
 	return "", nil
 }
 
 func GetFileAuditLogs(ctx contractapi.TransactionContextInterface, fileID string) (string, error) {
+	if err := requireAuditAccess(ctx, fileID); err != nil {
+		return "", err
+	}
+
 	// Create a partial composite key to find all audit logs for this file
 	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("audit", []string{fileID})
 	if err != nil {
@@ -157,3 +350,212 @@ func GetFileAuditLogs(ctx contractapi.TransactionContextInterface, fileID string
 
 	return string(logsJSON), nil
 }
+
+// Returns one page of audit entries across files as an AuditLogPage, filled up to pageSize.
+// The most selective filter field picks the index to scan and From and To pick the days of
+// it to visit; the rest of the filter is applied to each entry. Entries of files the caller
+// cannot read are left out. Entries written before the indexes existed only show up in
+// queries without a file ID once BackfillAuditIndexes has added them.
+func QueryAuditLogs(ctx contractapi.TransactionContextInterface, filter string, pageSize int32, bookmark string) (string, error) {
+	if pageSize <= 0 {
+		return "", fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	var auditFilter models.AuditFilter
+	if filter != "" {
+		if err := json.Unmarshal([]byte(filter), &auditFilter); err != nil {
+			return "", fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+	if err := auditFilter.Validate(); err != nil {
+		return "", err
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The audit trail of a file is as confidential as the file itself
+	readable := map[string]bool{}
+	keep := func(log models.AuditLog) (bool, error) {
+		if !auditFilter.Matches(log) {
+			return false, nil
+		}
+		allowed, checked := readable[log.FileID]
+		if !checked {
+			file, err := readFile(ctx, log.FileID)
+			if err != nil {
+				return false, err
+			}
+			allowed = file == nil || caller.canRead(file)
+			readable[log.FileID] = allowed
+		}
+		return allowed, nil
+	}
+
+	page := models.AuditLogPage{Logs: []models.AuditLog{}}
+	scan := &auditScan{pageSize: pageSize, keep: keep, page: &page}
+	switch {
+	case auditFilter.FileID != "":
+		page.Bookmark, err = scan.fill(ctx, "audit", []string{auditFilter.FileID}, bookmark)
+	case auditFilter.UserID != "":
+		page.Bookmark, err = scan.days(ctx, auditUserIndex, []string{auditFilter.UserID}, auditFilter, bookmark)
+	case auditFilter.OrgID != "":
+		page.Bookmark, err = scan.days(ctx, auditOrgIndex, []string{auditFilter.OrgID}, auditFilter, bookmark)
+	case auditFilter.Action != "":
+		page.Bookmark, err = scan.days(ctx, auditActionIndex, []string{auditFilter.Action}, auditFilter, bookmark)
+	default:
+		page.Bookmark, err = scan.days(ctx, auditDayIndex, []string{}, auditFilter, bookmark)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit log page: %v", err)
+	}
+
+	return string(pageJSON), nil
+}
+
+// One page of an audit query, filled from the entries themselves or from an audit index
+type auditScan struct {
+	pageSize int32
+	keep     func(models.AuditLog) (bool, error)
+	page     *models.AuditLogPage
+	lastDay  string // Index keys of later days end the scan, empty for no bound
+}
+
+func (s *auditScan) full() bool {
+	return int32(len(s.page.Logs)) >= s.pageSize
+}
+
+// Visits the days of an audit index within the filter's time bounds. Without a lower bound
+// the index is scanned from its first day. Bookmarks of bounded queries name the day they
+// resume on, followed by the peer's bookmark within that day.
+func (s *auditScan) days(ctx contractapi.TransactionContextInterface, index string, attributes []string, filter models.AuditFilter, bookmark string) (string, error) {
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	to := txTime
+	if filter.To != "" {
+		to, _ = time.Parse(time.RFC3339, filter.To) // Validated by QueryAuditLogs
+	}
+	s.lastDay = to.UTC().Format(auditDayLayout)
+	if filter.From == "" {
+		return s.fill(ctx, index, attributes, bookmark)
+	}
+
+	from, _ := time.Parse(time.RFC3339, filter.From)
+	day, inner := from.UTC().Format(auditDayLayout), ""
+	if bookmark != "" {
+		var found bool
+		if day, inner, found = strings.Cut(bookmark, "/"); !found {
+			return "", fmt.Errorf("invalid bookmark %q", bookmark)
+		}
+	}
+
+	for ; day <= s.lastDay; day, inner = nextAuditDay(day), "" {
+		if s.full() {
+			return day + "/", nil
+		}
+		dayAttributes := append(append([]string{}, attributes...), day)
+		next, err := s.fill(ctx, index, dayAttributes, inner)
+		if err != nil {
+			return "", err
+		}
+		if next != "" {
+			return day + "/" + next, nil
+		}
+	}
+	return "", nil
+}
+
+// Fills the page from one partition of the audit entries or of an audit index, returning
+// the bookmark to resume from, or "" once the partition is exhausted
+func (s *auditScan) fill(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, bookmark string) (string, error) {
+	for !s.full() {
+		iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(objectType, attributes, s.pageSize-int32(len(s.page.Logs)), bookmark)
+		if err != nil {
+			return "", fmt.Errorf("failed to query audit logs: %v", err)
+		}
+		pastLastDay, err := s.read(ctx, objectType, iterator)
+		iterator.Close()
+		if err != nil || pastLastDay {
+			return "", err
+		}
+
+		bookmark = ""
+		if metadata != nil {
+			s.page.FetchedRecordsCount += metadata.FetchedRecordsCount
+			bookmark = metadata.Bookmark
+		}
+		if bookmark == "" {
+			return "", nil
+		}
+	}
+	return bookmark, nil
+}
+
+// Adds the entries an iterator yields to the page, reporting whether it reached an index key
+// past the last day
+func (s *auditScan) read(ctx contractapi.TransactionContextInterface, objectType string, iterator shim.StateQueryIteratorInterface) (bool, error) {
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return false, fmt.Errorf("failed to iterate audit logs: %v", err)
+		}
+
+		// Index keys hold the key of their entry
+		logJSON := response.Value
+		if objectType != "audit" {
+			_, attributes, err := ctx.GetStub().SplitCompositeKey(response.Key)
+			if err != nil {
+				return false, fmt.Errorf("failed to split audit index key: %v", err)
+			}
+			if s.lastDay != "" && auditIndexDay(objectType, attributes) > s.lastDay {
+				return true, nil
+			}
+			if logJSON, err = ctx.GetStub().GetState(string(response.Value)); err != nil {
+				return false, fmt.Errorf("failed to read audit log: %v", err)
+			}
+			if logJSON == nil {
+				continue // Index entry without its audit entry
+			}
+		}
+
+		var log models.AuditLog
+		if err := json.Unmarshal(logJSON, &log); err != nil {
+			fmt.Printf("ERROR: Failed to unmarshal audit log: %v\n", err)
+			continue // Skip invalid entries instead of failing
+		}
+		keep, err := s.keep(log)
+		if err != nil {
+			return false, err
+		}
+		if keep {
+			s.page.Logs = append(s.page.Logs, log)
+		}
+	}
+	return false, nil
+}
+
+// Returns the day attribute of an audit index key, which follows the indexed value
+func auditIndexDay(index string, attributes []string) string {
+	position := 1
+	if index == auditDayIndex {
+		position = 0
+	}
+	if len(attributes) <= position {
+		return ""
+	}
+	return attributes[position]
+}
+
+func nextAuditDay(day string) string {
+	parsed, _ := time.Parse(auditDayLayout, day)
+	return parsed.AddDate(0, 0, 1).Format(auditDayLayout)
+}
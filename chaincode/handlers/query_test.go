@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestQueryFilesPageFillsPagesPastHiddenFiles(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=bob::CN=ca.org2"}
+	start := time.Date(2025, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	// Org2 can read every third file only
+	p := newPeer("peer0.org1")
+	want := map[string]bool{}
+	for i := 0; i < 9; i++ {
+		id, readers := fmt.Sprintf("file%d", i), `[]`
+		if i%3 == 0 {
+			readers = `[{"mspId":"Org2MSP"}]`
+			want[id] = true
+		}
+		p.endorse(t, fmt.Sprintf("tx%d", i), start, alice, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, id+".txt", "Qm"+id, "Org1", `{}`, "",
+				`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, "", readers, "")
+			return err
+		})
+	}
+
+	seen := map[string]bool{}
+	bookmark := ""
+	for pages := 1; ; pages++ {
+		var page models.FilePage
+		p.endorse(t, fmt.Sprintf("page%d", pages), start, bob, func(ctx contractapi.TransactionContextInterface) error {
+			pageJSON, err := QueryFilesPage(ctx, 2, bookmark, "")
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(pageJSON), &page)
+		})
+
+		for _, file := range page.Files {
+			if !want[file.ID] || seen[file.ID] {
+				t.Fatalf("page %d returned %s, which Org2 cannot read or already saw", pages, file.ID)
+			}
+			seen[file.ID] = true
+		}
+
+		bookmark = page.Bookmark
+		if bookmark == "" {
+			break
+		}
+		if len(page.Files) != 2 {
+			t.Fatalf("page %d holds %d files but more follow, want a full page", pages, len(page.Files))
+		}
+		if pages > 3 {
+			t.Fatal("paging does not end")
+		}
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("pages returned %d files, want all %d Org2 can read", len(seen), len(want))
+	}
+}
+
+func TestQueryFilesOnlyRunsFileSelectors(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=bob::CN=ca.org2"}
+	start := time.Date(2025, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	for id, readers := range map[string]string{"shared": `[{"mspId":"Org2MSP"}]`, "internal": `[]`} {
+		p.endorse(t, "register-"+id, start, alice, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, id+".txt", "Qm"+id, "Org1", `{}`, "",
+				`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, "", readers, "")
+			return err
+		})
+	}
+
+	query := func(txID string, caller *fakeIdentity, selector string) (*models.FilePage, error) {
+		var page models.FilePage
+		err := p.invoke(txID, start, caller, func(ctx contractapi.TransactionContextInterface) error {
+			pageJSON, err := QueryFiles(ctx, selector, 50, "")
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(pageJSON), &page)
+		})
+		return &page, err
+	}
+
+	// Selectors that reach beyond file fields or known operators never get to the database
+	for _, selector := range []string{
+		`{}`,
+		`{"orgId": "Org1MSP"}`,
+		`{"metadata": {"$regex": "secret"}}`,
+		`{"$or": [{"status": "PENDING"}, {"details": {"$exists": true}}]}`,
+		`{"$not": {"action": "REGISTER"}}`,
+		`{"$and": "status"}`,
+		`{"status": {"$where": "1"}}`,
+		`{"requiredOrgs": {"$elemMatch": {"$regex": ".", "userId": "x"}}}`,
+		`not json`,
+	} {
+		p.stub.query = ""
+		if _, err := query("tx-"+selector, alice, selector); err == nil {
+			t.Errorf("accepted selector %s", selector)
+		}
+		if p.stub.query != "" {
+			t.Errorf("ran a query for rejected selector %s", selector)
+		}
+	}
+
+	page, err := query("tx1", bob, `{"$or": [{"status": "APPROVED"}, {"requiredOrgs": {"$elemMatch": {"$eq": "Org1MSP"}}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Retired files are left out unless asked about
+	var run struct {
+		Selector map[string]json.RawMessage `json:"selector"`
+	}
+	if err := json.Unmarshal([]byte(p.stub.query), &run); err != nil {
+		t.Fatal(err)
+	}
+	if string(run.Selector["tombstone"]) != `{"$exists":false}` {
+		t.Fatalf("query %s does not exclude tombstoned files", p.stub.query)
+	}
+
+	// Selectors that ask about retired files, even inside a combinator, see them
+	for i, selector := range []string{
+		`{"tombstone.state": "ARCHIVED"}`,
+		`{"$and": [{"status": "APPROVED"}, {"tombstone.state": "DELETED"}]}`,
+		`{"$or": [{"status": "PENDING"}, {"$not": {"tombstone.state": {"$exists": false}}}]}`,
+	} {
+		if _, err := query(fmt.Sprintf("tx-tombstone-%d", i), bob, selector); err != nil {
+			t.Fatal(err)
+		}
+		var asked struct {
+			Selector map[string]json.RawMessage `json:"selector"`
+		}
+		if err := json.Unmarshal([]byte(p.stub.query), &asked); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := asked.Selector["tombstone"]; ok {
+			t.Fatalf("query %s excludes the tombstoned files it asks about", p.stub.query)
+		}
+	}
+
+	// Results are checked against the file's readers like any other read
+	if len(page.Files) != 1 || page.Files[0].ID != "shared" {
+		t.Fatalf("Org2 got %v, want only the shared file", page.Files)
+	}
+}
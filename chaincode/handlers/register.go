@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"dltfm/pkg/models"
+	"dltfm/pkg/models/policy"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -13,44 +17,205 @@ import (
 type EndorsementConfig struct {
 	RequiredOrgs []string `json:"requiredOrgs"`
 	PolicyType   string   `json:"policyType"`
+	Policy       string   `json:"policy,omitempty"`      // Policy expression, used when PolicyType is CUSTOM
+	Workflow     string   `json:"workflow,omitempty"`    // Workflow ID, used when PolicyType is WORKFLOW
+	Deadline     string   `json:"deadline,omitempty"`    // RFC3339 time after which approvals are refused
+	Private      bool     `json:"private,omitempty"`     // Keep the metadata in a private data collection, passed as transient data
+	RetainUntil  string   `json:"retainUntil,omitempty"` // RFC3339 time before which the file cannot be archived or deleted
 }
 
-func RegisterFile(ctx contractapi.TransactionContextInterface, id string, name string, ipfsCID string, owner string, metadata string, previousID string, endorsementConfig string) error {
+func RegisterFile(ctx contractapi.TransactionContextInterface, id string, name string, ipfsCID string, owner string, metadata string, previousID string, endorsementConfig string, duplicatePolicy string, readers string, versionPolicy string) (string, error) {
 	fmt.Printf("DEBUG: RegisterFile called with id=%s, name=%s\n", id, name)
 
+	// Without an ID the file is named after the transaction, so clients cannot pick one that collides
+	if id == "" {
+		id = fileIDFromTx(ctx)
+	}
+
+	// Never overwrite an existing record and its approvals
+	existingJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingJSON != nil {
+		return "", &models.IDConflict{ID: id}
+	}
+
 	// Parse endorsement config
 	var config EndorsementConfig
 	if err := json.Unmarshal([]byte(endorsementConfig), &config); err != nil {
-		return fmt.Errorf("invalid endorsement config: %v", err)
+		return "", fmt.Errorf("invalid endorsement config: %v", err)
+	}
+
+	// Validate the policy type and compile the approval policy.
+	// Workflow files start out under the policy of the workflow's first stage.
+	var approvalPolicy *policy.Policy
+	var workflow *models.Workflow
+	if config.PolicyType == PolicyTypeWorkflow {
+		workflow, approvalPolicy, err = startWorkflow(ctx, config.Workflow)
+	} else {
+		approvalPolicy, err = buildPolicy(config)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Every organization that approves the file, including those of later workflow stages
+	approverOrgs := approvalPolicy.Orgs()
+	if workflow != nil {
+		if approverOrgs, err = workflowOrgs(workflow); err != nil {
+			return "", err
+		}
+	}
+	if err := requireChannelMembers(ctx, approverOrgs); err != nil {
+		return "", err
 	}
 
-	// Validate policy type
-	if config.PolicyType != "ANY_ORG" && config.PolicyType != "ALL_ORGS" && config.PolicyType != "SPECIFIC_ORGS" {
-		return fmt.Errorf("invalid policy type: %s", config.PolicyType)
+	readRules, err := parseReaders(readers)
+	if err != nil {
+		return "", err
 	}
 
+	// Private files take their owner name and metadata from the transient map and share them
+	// only with the approvers
+	var privateCollection string
+	if config.Private {
+		if owner != "" || metadata != "" {
+			return "", fmt.Errorf("private registrations must pass their owner and metadata as transient data, not as arguments")
+		}
+		if owner, err = transientValue(ctx, transientOwnerKey); err != nil {
+			return "", err
+		}
+		if metadata, err = transientValue(ctx, transientMetadataKey); err != nil {
+			return "", err
+		}
+		privateCollection = privateCollectionName(approverOrgs)
+	}
+
+	// Metadata must fit the schema; it is stored at the current schema version
+	fileInfo, err := models.NormalizeMetadata(metadata)
+	if err != nil {
+		return "", fmt.Errorf("invalid metadata: %v", err)
+	}
+	metadata = fileInfo.String()
+
 	// Note: We no longer compute the hash of the content here as it's not available.
 	// Instead, we'll store the IPFS CID which already serves as a content hash.
 	hash := ipfsCID // IPFS CID is already a content-addressed hash
 	fmt.Printf("DEBUG: Using IPFS CID as hash: %s\n", hash)
 
+	// Decide what to do if this content is already registered under another ID
+	duplicatePolicy, err = parseDuplicatePolicy(duplicatePolicy)
+	if err != nil {
+		return "", err
+	}
+
+	existingIDs, err := getFileIDsByHash(ctx, hash)
+	if err != nil {
+		return "", err
+	}
+
+	var duplicateOf string
+	if len(existingIDs) > 0 {
+		if duplicatePolicy == DuplicateReject {
+			return "", fmt.Errorf("content %s is already registered as file %s", hash, existingIDs[0])
+		}
+		if duplicatePolicy == DuplicateLink {
+			duplicateOf = existingIDs[0]
+		}
+	}
+
+	versionPolicy, err = parseVersionPolicy(versionPolicy)
+	if err != nil {
+		return "", err
+	}
+
+	// Get submitting org's MSP ID
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	// The submitting user owns the file until they transfer it
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client ID: %v", err)
+	}
+	ownerMSP, ownerID := mspID, clientID
+	var editors []models.ReadRule
+	var successorIDs []string
+
 	var newVersion int
-	timestamp := time.Now().Format(time.RFC3339)
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	timestamp := txTime.Format(time.RFC3339)
+
+	var deadline string
+	if config.Deadline != "" {
+		parsed, err := parseDeadline(config.Deadline, txTime)
+		if err != nil {
+			return "", err
+		}
+		deadline = parsed.Format(time.RFC3339)
+	}
+
+	var retainUntil string
+	if config.RetainUntil != "" {
+		parsed, err := parseRetainUntil(config.RetainUntil, txTime)
+		if err != nil {
+			return "", err
+		}
+		retainUntil = parsed.Format(time.RFC3339)
+	}
 
 	if previousID != "" {
 		// Fetch the previous version
 		existingFileJSON, err := GetFileByID(ctx, previousID)
 		if err != nil {
-			return fmt.Errorf("error fetching previous file: %v", err)
+			return "", fmt.Errorf("error fetching previous file: %v", err)
 		}
 		if existingFileJSON == "" {
-			return fmt.Errorf("previous file ID %s not found", previousID)
+			return "", fmt.Errorf("previous file ID %s not found", previousID)
 		}
 
 		var previousFile models.File
 		err = json.Unmarshal([]byte(existingFileJSON), &previousFile)
 		if err != nil {
-			return fmt.Errorf("error unmarshaling previous file: %v", err)
+			return "", fmt.Errorf("error unmarshaling previous file: %v", err)
+		}
+
+		if err := requireLive(&previousFile); err != nil {
+			return "", err
+		}
+
+		// Only the owner and editors of a chain may extend it
+		editor, err := canEdit(ctx, &previousFile)
+		if err != nil {
+			return "", err
+		}
+		if !editor {
+			return "", fmt.Errorf("only the owner or an editor of file %s can register a new version of it", previousID)
+		}
+
+		// A parent that already has a successor is stale; branching it must be deliberate
+		if successorIDs, err = getSuccessorIDs(ctx, previousID); err != nil {
+			return "", err
+		}
+		if len(successorIDs) > 0 && versionPolicy != VersionFork {
+			return "", &models.VersionConflict{PreviousID: previousID, Successors: successorIDs}
+		}
+
+		// The chain keeps its owner and editors, whoever registers the version.
+		// Chains started before ownership records pass to the submitter.
+		// A private parent keeps its owner name off the public record; the new version names its own.
+		if previousFile.OwnerMSP != "" {
+			if previousFile.Owner != "" {
+				owner = previousFile.Owner
+			}
+			ownerMSP, ownerID = previousFile.OwnerMSP, previousFile.OwnerID
+			editors = previousFile.Editors
 		}
 
 		newVersion = previousFile.Version + 1
@@ -60,42 +225,85 @@ func RegisterFile(ctx contractapi.TransactionContextInterface, id string, name s
 		fmt.Printf("DEBUG: Creating new file (not a versioned update)\n")
 	}
 
-	// Get submitting org's MSP ID
-	mspID, err := ctx.GetClientIdentity().GetMSPID()
-	if err != nil {
-		return fmt.Errorf("failed to get MSP ID: %v", err)
+	// Lift the MIME type and size out of the metadata so rich queries can index them.
+	// Private owner names and metadata stay off the public ledger entirely.
+	publicOwner, publicMetadata, mimeType, size := owner, metadata, fileInfo.Type, fileInfo.Size
+	if privateCollection != "" {
+		publicOwner, publicMetadata, mimeType, size = "", "", "", 0
 	}
 
-	// Initialize approvals array
-	initialApprovals := []string{mspID}
+	// The submitter approves their own upload, provided the policy gives their org a say
+	initialApprovals := []string{}
+	var approvals []models.Approval
+	if approvalPolicy.Mentions(mspID) {
+		approval, err := newApproval(ctx, mspID, txTime)
+		if err != nil {
+			return "", err
+		}
+		approvals = append(approvals, approval)
+		initialApprovals = append(initialApprovals, mspID)
+	}
 
 	// Store new file entry
 	file := models.File{
-		ID:               id,
-		Name:             name,
-		Hash:             hash,
-		Timestamp:        timestamp,
-		Owner:            owner,
-		Metadata:         metadata,
-		Version:          newVersion,
-		PreviousID:       previousID,
-		IPFSLocation:     ipfsCID, // Store IPFS CID instead of content
-		Status:           "PENDING",
-		RequiredOrgs:     config.RequiredOrgs,
-		CurrentApprovals: initialApprovals,
-		EndorsementType:  config.PolicyType,
-	}
-
-	// Set status to APPROVED if using ANY_ORG and submitter is in RequiredOrgs
-	if config.PolicyType == "ANY_ORG" && contains(config.RequiredOrgs, mspID) {
-		file.Status = "APPROVED"
+		ID:                id,
+		Name:              name,
+		Hash:              hash,
+		Timestamp:         timestamp,
+		Owner:             publicOwner,
+		OwnerMSP:          ownerMSP,
+		OwnerID:           ownerID,
+		Readers:           withOrgReaders(readRules, append([]string{mspID, ownerMSP}, approverOrgs...)...), // Submitter, owner and approvers can always read
+		Editors:           editors,
+		Metadata:          publicMetadata,
+		MimeType:          mimeType,
+		Size:              size,
+		Version:           newVersion,
+		PreviousID:        previousID,
+		DuplicateOf:       duplicateOf,
+		IPFSLocation:      ipfsCID, // Store IPFS CID instead of content
+		Status:            "PENDING",
+		ApprovalDeadline:  deadline,
+		RetainUntil:       retainUntil,
+		RequiredOrgs:      approvalPolicy.Orgs(),
+		CurrentApprovals:  initialApprovals,
+		Approvals:         approvals,
+		EndorsementType:   config.PolicyType,
+		EndorsementPolicy: approvalPolicy.String(),
+	}
+
+	if workflow != nil {
+		file.WorkflowID = workflow.ID
+		file.CurrentStage = 1
+	}
+
+	if privateCollection != "" {
+		file.PrivateCollection = privateCollection
+		file.PrivateDataHash, err = putPrivateDetails(ctx, privateCollection, models.FilePrivateDetails{
+			ID:       id,
+			Owner:    owner,
+			Metadata: metadata,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Only the required organizations' peers may endorse later updates to the file
+	if err := setFileEndorsementPolicy(ctx, &file); err != nil {
+		return "", err
+	}
+
+	// The submitter's own approval may already satisfy the policy, e.g. ANY_ORG
+	if err := settleApprovals(ctx, &file, approvalPolicy, txTime); err != nil {
+		return "", err
 	}
 
 	fmt.Printf("DEBUG: Registering file - ID: %s, PreviousID: %s\n", file.ID, file.PreviousID)
 
 	fileJSON, err := json.Marshal(file)
 	if err != nil {
-		return fmt.Errorf("error marshalling file: %s", err.Error())
+		return "", fmt.Errorf("error marshalling file: %s", err.Error())
 	}
 
 	fmt.Printf("DEBUG: Saving file with JSON: %s\n", string(fileJSON))
@@ -103,17 +311,67 @@ func RegisterFile(ctx contractapi.TransactionContextInterface, id string, name s
 	// Save to state
 	err = ctx.GetStub().PutState(id, fileJSON)
 	if err != nil {
-		return fmt.Errorf("failed to save file to world state: %v", err)
+		return "", fmt.Errorf("failed to save file to world state: %v", err)
 	}
 
-	// Audit the transaction
-	details := fmt.Sprintf("File %s registered by %s with endorsement type %s", name, owner, config.PolicyType)
+	// Link the previous version forward to this one so the chain can be walked in both directions
+	if previousID != "" {
+		if err := putSuccessorIndex(ctx, previousID, id); err != nil {
+			return "", err
+		}
+	}
+
+	// Files still pending at their deadline are picked up by ExpirePendingFiles
+	if file.Status == "PENDING" && deadline != "" {
+		if err := putDeadlineIndex(ctx, deadline, id); err != nil {
+			return "", err
+		}
+	}
+
+	// Index the content hash so duplicates and hash lookups don't need a full scan
+	if err := putHashIndex(ctx, hash, id); err != nil {
+		return "", err
+	}
+
+	// Audit the transaction, naming private owners by organization only
+	registrant := publicOwner
+	if privateCollection != "" {
+		registrant = ownerMSP
+	}
+	details := fmt.Sprintf("File %s registered by %s with endorsement type %s and policy %s", name, registrant, config.PolicyType, approvalPolicy.String())
+	if deadline != "" {
+		details += fmt.Sprintf("; approval deadline %s", deadline)
+	}
+	if retainUntil != "" {
+		details += fmt.Sprintf("; retained until %s", retainUntil)
+	}
+	if privateCollection != "" {
+		details += fmt.Sprintf("; metadata kept in private collection %s", privateCollection)
+	}
+	if workflow != nil {
+		details += fmt.Sprintf("; workflow %s stage 1 (%s)", workflow.ID, workflow.Stages[0].Name)
+	}
+	if len(successorIDs) > 0 {
+		details += fmt.Sprintf("; forks %s alongside %s", previousID, strings.Join(successorIDs, ", "))
+	}
+	if len(existingIDs) > 0 {
+		details += fmt.Sprintf("; duplicate content of %s, policy %s", strings.Join(existingIDs, ", "), duplicatePolicy)
+	}
 	if err := CreateAuditLog(ctx, id, "REGISTER", details); err != nil {
-		// Log the error but don't fail the transaction
-		fmt.Printf("WARNING: Failed to create audit log: %v\n", err)
+		return "", err
+	}
+
+	if err := emitFileEvent(ctx, models.EventFileRegistered, &file); err != nil {
+		return "", err
 	}
 
-	return nil
+	return id, nil
+}
+
+// Derives a file ID from the transaction ID, the same on every endorsing peer
+func fileIDFromTx(ctx contractapi.TransactionContextInterface) string {
+	hash := sha256.Sum256([]byte(ctx.GetStub().GetTxID()))
+	return hex.EncodeToString(hash[:16])
 }
 
 // Helper function to check if a string is in a slice
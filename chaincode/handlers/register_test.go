@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRegisterNeverOverwritesAnExistingID(t *testing.T) {
+	org1User := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	org2User := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=user1::CN=ca.org2"}
+	start := time.Date(2025, 9, 8, 9, 0, 0, 0, time.UTC)
+
+	var registeredID string
+	register := func(id string, hash string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			registeredID, err = RegisterFile(ctx, id, "report.pdf", hash, "Org1", `{}`, "",
+				`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+			return err
+		}
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, org1User, register("file1", "QmOriginal"))
+
+	err := p.invoke("tx2", start, org2User, register("file1", "QmReplacement"))
+	var conflict *models.IDConflict
+	if !errors.As(err, &conflict) || conflict.ID != "file1" {
+		t.Fatalf("reusing file1 returned %v, want an ID conflict", err)
+	}
+	if got := p.file(t, "file1").Hash; got != "QmOriginal" {
+		t.Fatalf("file1 hash = %s, want the original QmOriginal", got)
+	}
+
+	// Without an ID, every peer derives the same one from the transaction
+	other := newPeer("peer0.org2")
+	p.endorse(t, "tx3", start, org1User, register("", "QmDerived"))
+	firstID := registeredID
+	other.endorse(t, "tx3", start, org1User, register("", "QmDerived"))
+	if firstID == "" || registeredID != firstID {
+		t.Fatalf("derived IDs %q and %q, want the same non-empty ID", firstID, registeredID)
+	}
+	if got := p.file(t, firstID).Hash; got != "QmDerived" {
+		t.Fatalf("derived file has hash %s, want QmDerived", got)
+	}
+}
+
+func TestRegisterValidatesMetadata(t *testing.T) {
+	org1User := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=user1::CN=ca.org1"}
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+
+	register := func(id string, metadata string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, id+".pdf", "Qm"+id, "Org1", metadata, "",
+				`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, "", "", "")
+			return err
+		}
+	}
+
+	p := newPeer("peer0.org1")
+	rejected := map[string]string{
+		"not JSON":            `size=42`,
+		"bad MIME type":       `{"size":42,"type":"pdf"}`,
+		"negative size":       `{"size":-1,"type":"application/pdf"}`,
+		"bad created time":    `{"size":42,"createdAt":"yesterday"}`,
+		"unknown version":     `{"schemaVersion":2,"size":42}`,
+		"unknown field":       `{"schemaVersion":1,"size":42,"client":"Acme Corp"}`,
+		"non-string extra":    `{"size":42,"pages":12}`,
+		"non-numeric size":    `{"size":"42"}`,
+		"non-string custom":   `{"schemaVersion":1,"custom":{"pages":12}}`,
+		"too long custom key": `{"schemaVersion":1,"custom":{"` + strings.Repeat("k", 65) + `":"v"}}`,
+	}
+	for name, metadata := range rejected {
+		if err := p.invoke("tx-"+name, start, org1User, register("bad", metadata)); err == nil {
+			t.Errorf("registered a file with %s metadata %s", name, metadata)
+		}
+	}
+	if _, ok := p.stub.State["bad"]; ok {
+		t.Fatal("a file with bad metadata was stored")
+	}
+
+	// Metadata in the shape clients sent before the schema is upgraded as it is stored
+	p.endorse(t, "tx1", start, org1User, register("legacy",
+		`{"size":42,"type":"application/pdf","createdAt":"2025-09-15T08:59:00.123Z","encoding":"base64","project":"apollo"}`))
+	file := p.file(t, "legacy")
+	metadata, err := models.ParseMetadata(file.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.SchemaVersion != models.MetadataSchemaVersion || metadata.Encoding != "base64" || metadata.Custom["project"] != "apollo" {
+		t.Fatalf("stored metadata %s, want the legacy fields at schema version %d", file.Metadata, models.MetadataSchemaVersion)
+	}
+	if file.MimeType != "application/pdf" || file.Size != 42 {
+		t.Fatalf("indexed MIME type %q and size %d, want application/pdf and 42", file.MimeType, file.Size)
+	}
+
+	// Records stored before any validation still decode, plain text as the description
+	if old, err := models.ParseMetadata("scanned by reception"); err != nil || old.Description != "scanned by reception" {
+		t.Fatalf("plain text metadata decoded to %+v, %v", old, err)
+	}
+	for _, raw := range []string{`"draft"`, `42`, `["a","b"]`} {
+		if old, err := models.ParseMetadata(raw); err != nil || old.Description != raw {
+			t.Fatalf("metadata %s decoded to %+v, %v, want it as the description", raw, old, err)
+		}
+	}
+
+	// Legacy fields of an unexpected type are kept as custom values
+	old, err := models.ParseMetadata(`{"size":"42","type":"application/pdf","custom":"none","createdAt":1694768340}`)
+	if err != nil {
+		t.Fatalf("mistyped legacy metadata failed to decode: %v", err)
+	}
+	if old.Size != 0 || old.Type != "application/pdf" || old.Custom["size"] != "42" || old.Custom["custom"] != "none" || old.Custom["createdAt"] != "1694768340" {
+		t.Fatalf("mistyped legacy metadata decoded to %+v", old)
+	}
+
+	// New files must still type their fields
+	if err := p.invoke("tx2", start, org1User, register("mistyped", `{"size":"42"}`)); err == nil {
+		t.Fatal("registered a file whose size is a string")
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Rejects a pending file on behalf of the caller's organization.
+// REJECTED is terminal, the file can no longer be approved.
+func RejectFile(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("a reason is required to reject a file")
+	}
+
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("file does not exist: %s", id)
+	}
+	if err := requireLive(file); err != nil {
+		return err
+	}
+
+	if file.Status != "PENDING" {
+		return fmt.Errorf("file %s is %s, only pending files can be rejected", id, file.Status)
+	}
+
+	// Get the MSP ID of the rejecting organization
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	// Only organizations named in the approval policy get a say in rejecting it
+	approvalPolicy, err := filePolicy(file)
+	if err != nil {
+		return err
+	}
+	if !approvalPolicy.Mentions(mspID) {
+		return fmt.Errorf("organization %s is not part of the approval policy of file %s", mspID, id)
+	}
+
+	file.Status = "REJECTED"
+	file.RejectionReason = reason
+	file.RejectedBy = mspID
+
+	// Update state
+	updatedFileJSON, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated file: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(id, updatedFileJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update file state: %v", err)
+	}
+
+	// Create audit log entry
+	details := fmt.Sprintf("Organization %s rejected file %s: %s", mspID, file.Name, reason)
+	if err := CreateAuditLog(ctx, id, "REJECT", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileRejected, file)
+}
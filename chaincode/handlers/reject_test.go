@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRejectedFilesTakeNoMoreDecisions(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	carol := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=carol::CN=ca.org2"}
+	dave := &fakeIdentity{mspID: "Org3MSP", id: "x509::CN=dave::CN=ca.org3"}
+	start := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	register := func(id string, config string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, "contract.pdf", "Qm"+id, "Org1", `{}`, "", config, "", "", "")
+			return err
+		}
+	}
+	reject := func(id string, reason string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return RejectFile(ctx, id, reason)
+		}
+	}
+	approve := func(id string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return ApproveFile(ctx, id)
+		}
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, register("file1", `{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS"}`))
+
+	if err := p.invoke("tx2", start, carol, reject("file1", "")); err == nil {
+		t.Fatal("rejected without a reason")
+	}
+	if err := p.invoke("tx3", start, dave, reject("file1", "not ours")); err == nil {
+		t.Fatal("an organization outside the policy rejected the file")
+	}
+
+	p.endorse(t, "tx4", start, carol, reject("file1", "wrong counterparty"))
+	file := p.file(t, "file1")
+	if file.Status != "REJECTED" || file.RejectionReason != "wrong counterparty" || file.RejectedBy != "Org2MSP" {
+		t.Fatalf("after rejection: status %s, reason %q, by %s", file.Status, file.RejectionReason, file.RejectedBy)
+	}
+	if entry := p.auditEntry(t, "file1", "REJECT"); !strings.Contains(entry.Details, "wrong counterparty") || entry.OrgID != "Org2MSP" {
+		t.Fatalf("REJECT audit entry %+v does not record the reason and organization", entry)
+	}
+
+	// REJECTED is terminal
+	for txID, handler := range map[string]func(contractapi.TransactionContextInterface) error{
+		"tx5": approve("file1"),
+		"tx6": reject("file1", "again"),
+		"tx7": func(ctx contractapi.TransactionContextInterface) error {
+			return RevokeApproval(ctx, "file1", "changed my mind")
+		},
+	} {
+		if err := p.invoke(txID, start, carol, handler); err == nil || !strings.Contains(err.Error(), "REJECTED") {
+			t.Errorf("%s on a rejected file returned %v, want it refused as REJECTED", txID, err)
+		}
+	}
+
+	// Approved files are just as settled
+	p.endorse(t, "tx8", start, alice, register("file2", `{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`))
+	if err := p.invoke("tx9", start, alice, approve("file2")); err == nil || !strings.Contains(err.Error(), "only pending files can be approved") {
+		t.Fatalf("approving an approved file returned %v", err)
+	}
+	if err := p.invoke("tx10", start, alice, reject("file2", "too late")); err == nil {
+		t.Fatal("rejected an approved file")
+	}
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefix for the organizations each owning organization lets place legal holds
+const legalHoldOrgsPrefix = "legalholdorgs"
+
+// Replaces the organizations that may place legal holds on files owned by the caller's
+// organization. Only admins of that organization may do this. Holders can narrow it
+// to their legal staff with an access policy on PlaceLegalHold.
+func SetLegalHoldOrgs(ctx contractapi.TransactionContextInterface, holdersJSON string) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	admin, err := callerIsAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("only admins of %s can change who may hold its files", mspID)
+	}
+
+	var holders []string
+	if err := json.Unmarshal([]byte(holdersJSON), &holders); err != nil {
+		return fmt.Errorf("invalid legal hold organizations: %v", err)
+	}
+	holders = uniqueOrgs(holders)
+	if err := requireChannelMembers(ctx, holders); err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	holdOrgsJSON, err := json.Marshal(models.LegalHoldOrgs{
+		MSPID:     mspID,
+		Holders:   holders,
+		UpdatedBy: clientID,
+		Timestamp: txTime.Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal legal hold organizations: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(legalHoldOrgsPrefix, []string{mspID})
+	if err != nil {
+		return fmt.Errorf("failed to create legal hold organizations key: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(key, holdOrgsJSON); err != nil {
+		return fmt.Errorf("failed to save legal hold organizations: %v", err)
+	}
+
+	return emitLedgerEvent(ctx, models.EventLegalHoldOrgsChanged, mspID, strings.Join(holders, ", "))
+}
+
+// Returns the organizations that may hold an organization's files as JSON, none if it never set them
+func GetLegalHoldOrgs(ctx contractapi.TransactionContextInterface, mspID string) (string, error) {
+	holdOrgs, err := readLegalHoldOrgs(ctx, mspID)
+	if err != nil {
+		return "", err
+	}
+
+	holdOrgsJSON, err := json.Marshal(holdOrgs)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal legal hold organizations: %v", err)
+	}
+
+	return string(holdOrgsJSON), nil
+}
+
+func readLegalHoldOrgs(ctx contractapi.TransactionContextInterface, mspID string) (*models.LegalHoldOrgs, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(legalHoldOrgsPrefix, []string{mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create legal hold organizations key: %v", err)
+	}
+
+	holdOrgsJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legal hold organizations: %v", err)
+	}
+	if holdOrgsJSON == nil {
+		return &models.LegalHoldOrgs{MSPID: mspID, Holders: []string{}}, nil
+	}
+
+	var holdOrgs models.LegalHoldOrgs
+	if err := json.Unmarshal(holdOrgsJSON, &holdOrgs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal legal hold organizations: %v", err)
+	}
+
+	return &holdOrgs, nil
+}
+
+// Parses a retention date, which must not be in the past, returning it in UTC
+func parseRetainUntil(retainUntil string, txTime time.Time) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, retainUntil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid retention date %q, expected RFC3339: %v", retainUntil, err)
+	}
+	if parsed.Before(txTime) {
+		return time.Time{}, fmt.Errorf("retention date %s is in the past", retainUntil)
+	}
+	return parsed.UTC(), nil
+}
+
+// Refuses to archive or delete a file that is under a legal hold or still within its retention period
+func requireDisposable(file *models.File, txTime time.Time) error {
+	if len(file.LegalHolds) > 0 {
+		return fmt.Errorf("file %s is under legal hold %s", file.ID, file.LegalHolds[0].ID)
+	}
+	if file.RetainUntil == "" {
+		return nil
+	}
+
+	retainUntil, err := time.Parse(time.RFC3339, file.RetainUntil)
+	if err != nil {
+		return fmt.Errorf("stored retention date of file %s is invalid: %v", file.ID, err)
+	}
+	if txTime.Before(retainUntil) {
+		return fmt.Errorf("file %s must be retained until %s", file.ID, file.RetainUntil)
+	}
+	return nil
+}
+
+// Extends the retention period of a file. Only the owner may set it, and never to an earlier date.
+func SetRetention(ctx contractapi.TransactionContextInterface, id string, retainUntil string) error {
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("file does not exist: %s", id)
+	}
+	if err := requireLive(file); err != nil {
+		return err
+	}
+	if file.OwnerMSP == "" {
+		return fmt.Errorf("file %s predates ownership records and has no owner", id)
+	}
+
+	owner, err := isOwner(ctx, file)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		return fmt.Errorf("only the owner of file %s can set its retention", id)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	parsed, err := parseRetainUntil(retainUntil, txTime)
+	if err != nil {
+		return err
+	}
+	if file.RetainUntil != "" {
+		current, err := time.Parse(time.RFC3339, file.RetainUntil)
+		if err != nil {
+			return fmt.Errorf("stored retention date of file %s is invalid: %v", id, err)
+		}
+		if parsed.Before(current) {
+			return fmt.Errorf("file %s is already retained until %s, retention can only be extended", id, file.RetainUntil)
+		}
+	}
+	file.RetainUntil = parsed.Format(time.RFC3339)
+
+	if err := putFile(ctx, file); err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("File %s retained until %s", file.Name, file.RetainUntil)
+	if err := CreateAuditLog(ctx, id, "SET_RETENTION", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileRetentionSet, file)
+}
+
+// Places a legal hold on a file. Archived and deleted files can be held too, which keeps
+// their content pinned.
+func PlaceLegalHold(ctx contractapi.TransactionContextInterface, id string, holdID string, reason string) error {
+	if holdID == "" || reason == "" {
+		return fmt.Errorf("a hold ID and a reason are required to place a legal hold")
+	}
+
+	file, mspID, err := legalHoldTarget(ctx, id)
+	if err != nil {
+		return err
+	}
+	if file.OwnerMSP == "" {
+		return fmt.Errorf("file %s predates ownership records and has no owner to designate who may hold it", id)
+	}
+	holdOrgs, err := readLegalHoldOrgs(ctx, file.OwnerMSP)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(holdOrgs.Holders, mspID) {
+		return fmt.Errorf("organization %s is not designated by %s to hold its files", mspID, file.OwnerMSP)
+	}
+	for _, hold := range file.LegalHolds {
+		if hold.ID == holdID {
+			return fmt.Errorf("file %s is already under legal hold %s", id, holdID)
+		}
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	file.LegalHolds = append(file.LegalHolds, models.LegalHold{
+		ID:       holdID,
+		Reason:   reason,
+		MSPID:    mspID,
+		ClientID: clientID,
+		PlacedAt: txTime.Format(time.RFC3339),
+	})
+
+	if err := putFile(ctx, file); err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("Organization %s placed legal hold %s on file %s: %s", mspID, holdID, file.Name, reason)
+	if err := CreateAuditLog(ctx, id, "PLACE_LEGAL_HOLD", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileLegalHoldPlaced, file)
+}
+
+// Lifts a legal hold from a file. Only the user who placed the hold, or an admin of their
+// organization, may release it, even if the owner has since stopped designating that organization.
+func ReleaseLegalHold(ctx contractapi.TransactionContextInterface, id string, holdID string) error {
+	file, mspID, err := legalHoldTarget(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+	admin, err := callerIsAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	holds := []models.LegalHold{}
+	for _, hold := range file.LegalHolds {
+		if hold.ID != holdID {
+			holds = append(holds, hold)
+			continue
+		}
+		if hold.MSPID != mspID || (hold.ClientID != clientID && !admin) {
+			return fmt.Errorf("legal hold %s on file %s can only be released by whoever placed it or an admin of %s", holdID, id, hold.MSPID)
+		}
+	}
+	if len(holds) == len(file.LegalHolds) {
+		return fmt.Errorf("file %s is not under legal hold %s", id, holdID)
+	}
+	file.LegalHolds = holds
+
+	if err := putFile(ctx, file); err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("Organization %s released legal hold %s on file %s", mspID, holdID, file.Name)
+	if err := CreateAuditLog(ctx, id, "RELEASE_LEGAL_HOLD", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileLegalHoldReleased, file)
+}
+
+// Reports whether any file registered with the content is under legal hold, deleted files included
+func IsContentHeld(ctx contractapi.TransactionContextInterface, hash string) (bool, error) {
+	ids, err := getFileIDsByHash(ctx, hash)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range ids {
+		file, err := readFile(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if file != nil && len(file.LegalHolds) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Returns the file a legal hold transaction targets and the caller's organization
+func legalHoldTarget(ctx contractapi.TransactionContextInterface, id string) (*models.File, string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if file == nil {
+		return nil, "", fmt.Errorf("file does not exist: %s", id)
+	}
+
+	return file, mspID, nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRetentionAndLegalHoldsBlockDeletion(t *testing.T) {
+	legal := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=counsel::CN=ca.org1"}
+	owner := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=owner::CN=ca.org2"}
+	start := time.Date(2025, 11, 3, 9, 0, 0, 0, time.UTC)
+	retainUntil := start.Add(30 * 24 * time.Hour)
+
+	p := newPeer("peer0.org2")
+	p.endorse(t, "tx1", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		config := fmt.Sprintf(`{"requiredOrgs":["Org2MSP"],"policyType":"ANY_ORG","retainUntil":%q}`, retainUntil.Format(time.RFC3339))
+		_, err := RegisterFile(ctx, "file1", "ledger.xlsx", "QmHeld", "Org2", `{}`, "", config, "", "", "")
+		return err
+	})
+
+	var report models.DeletionReport
+	deleteFile := func(ctx contractapi.TransactionContextInterface) error {
+		reportJSON, err := DeleteFile(ctx, "file1", "cleanup", false)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(reportJSON), &report)
+	}
+
+	if err := p.invoke("tx2", start.Add(time.Hour), owner, deleteFile); err == nil {
+		t.Fatal("deleted a file within its retention period")
+	}
+	if err := p.invoke("tx3", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		return SetRetention(ctx, "file1", start.Add(time.Hour).Format(time.RFC3339))
+	}); err == nil {
+		t.Fatal("shortened the retention period")
+	}
+
+	// The owning organization's admin designates who may hold its files, from registered members only
+	ownerAdmin := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=admin::CN=ca.org2", attrs: map[string]string{"hf.Type": "admin"}}
+	designate := func(holders string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return SetLegalHoldOrgs(ctx, holders)
+		}
+	}
+	if err := p.invoke("tx4a", start, owner, designate(`["Org1MSP"]`)); err == nil {
+		t.Fatal("a non-admin designated legal hold organizations")
+	}
+	if err := p.invoke("tx4b", start, ownerAdmin, designate(`["Org9MSP"]`)); err == nil {
+		t.Fatal("designated an organization that is not a channel member")
+	}
+
+	placeHold := func(ctx contractapi.TransactionContextInterface) error {
+		return PlaceLegalHold(ctx, "file1", "case-42", "pending litigation")
+	}
+	if err := p.invoke("tx4c", start, legal, placeHold); err == nil {
+		t.Fatal("placed a legal hold before the owner designated anyone")
+	}
+	p.endorse(t, "tx4d", start, ownerAdmin, designate(`["Org1MSP"]`))
+	if err := p.invoke("tx4", start, owner, placeHold); err == nil {
+		t.Fatal("an organization that is not designated placed a legal hold")
+	}
+	p.endorse(t, "tx5", start, legal, placeHold)
+
+	// A hold outlasts the retention period
+	afterRetention := retainUntil.Add(time.Hour)
+	if err := p.invoke("tx6", afterRetention, owner, deleteFile); err == nil {
+		t.Fatal("deleted a file under legal hold")
+	}
+
+	// The holding organization can read the file although it is no reader
+	p.endorse(t, "tx7", afterRetention, legal, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := GetFileByID(ctx, "file1")
+		return err
+	})
+
+	// Content no file uses is not held
+	var held bool
+	p.endorse(t, "tx7a", afterRetention, owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		held, err = IsContentHeld(ctx, "QmNeverRegistered")
+		return err
+	})
+	if held {
+		t.Fatal("content no file uses counts as held")
+	}
+
+	// Only whoever placed the hold, or an admin of their organization, releases it
+	releaseHold := func(ctx contractapi.TransactionContextInterface) error {
+		return ReleaseLegalHold(ctx, "file1", "case-42")
+	}
+	colleague := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=intern::CN=ca.org1"}
+	if err := p.invoke("tx8a", afterRetention, colleague, releaseHold); err == nil {
+		t.Fatal("another member of the holding organization released the hold")
+	}
+	if err := p.invoke("tx8b", afterRetention, ownerAdmin, releaseHold); err == nil {
+		t.Fatal("an admin of the owning organization released another party's hold")
+	}
+	p.endorse(t, "tx8", afterRetention, legal, releaseHold)
+	p.endorse(t, "tx9", afterRetention, owner, deleteFile)
+	if len(report.Unreferenced) != 1 || report.Unreferenced[0] != "QmHeld" {
+		t.Fatalf("unreferenced content %v, want [QmHeld]", report.Unreferenced)
+	}
+}
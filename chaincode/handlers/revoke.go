@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Withdraws the caller's approval from a file that is still pending
+func RevokeApproval(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("a reason is required to revoke an approval")
+	}
+
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("file does not exist: %s", id)
+	}
+	if err := requireLive(file); err != nil {
+		return err
+	}
+
+	if file.Status != "PENDING" {
+		return fmt.Errorf("file %s is %s, approvals can only be revoked while it is pending", id, file.Status)
+	}
+
+	// Get the MSP ID of the revoking organization
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+
+	// Remove the caller's own approval, keeping the order of the others
+	remaining := make([]models.Approval, 0, len(file.Approvals))
+	orgStillApproves := false
+	for _, approval := range file.Approvals {
+		if approval.ClientID == clientID {
+			continue
+		}
+		remaining = append(remaining, approval)
+		if approval.MSPID == mspID {
+			orgStillApproves = true
+		}
+	}
+
+	// Files approved before per-user records only know the org approved, any of its users may revoke that
+	legacyOrgApproval := contains(file.CurrentApprovals, mspID) && !orgStillApproves && len(remaining) == len(file.Approvals)
+	if len(remaining) == len(file.Approvals) && !legacyOrgApproval {
+		return fmt.Errorf("user %s of organization %s has not approved file %s", clientID, mspID, id)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	file.Approvals = remaining
+	if !orgStillApproves {
+		orgs := make([]string, 0, len(file.CurrentApprovals))
+		for _, approval := range file.CurrentApprovals {
+			if approval != mspID {
+				orgs = append(orgs, approval)
+			}
+		}
+		file.CurrentApprovals = orgs
+	}
+	file.Revocations = append(file.Revocations, models.ApprovalRevocation{
+		OrgID:     mspID,
+		ClientID:  clientID,
+		Reason:    reason,
+		Timestamp: txTime.Format(time.RFC3339),
+	})
+
+	// Update state
+	updatedFileJSON, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated file: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(id, updatedFileJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update file state: %v", err)
+	}
+
+	// Create audit log entry
+	details := fmt.Sprintf("User %s of organization %s revoked their approval of file %s: %s", clientID, mspID, file.Name, reason)
+	if err := CreateAuditLog(ctx, id, "REVOKE", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileApprovalRevoked, file)
+}
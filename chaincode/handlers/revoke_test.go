@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRevokeApprovalWithdrawsOnlyTheCallersApproval(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	carol := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=carol::CN=ca.org2"}
+	erin := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=erin::CN=ca.org2"}
+	dave := &fakeIdentity{mspID: "Org3MSP", id: "x509::CN=dave::CN=ca.org3"}
+	start := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	revoke := func(reason string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return RevokeApproval(ctx, "file1", reason)
+		}
+	}
+	approve := func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "file1")
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "contract.pdf", "QmFile1", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP","Org2MSP","Org3MSP"],"policyType":"ALL_ORGS"}`, "", "", "")
+		return err
+	})
+	p.endorse(t, "tx2", start, carol, approve)
+
+	if err := p.invoke("tx3", start, carol, revoke("")); err == nil {
+		t.Fatal("revoked without a reason")
+	}
+	if err := p.invoke("tx4", start, dave, revoke("never signed")); err == nil || !strings.Contains(err.Error(), "has not approved") {
+		t.Fatalf("revoking without an approval returned %v", err)
+	}
+	if err := p.invoke("tx5", start, erin, revoke("colleague's approval")); err == nil {
+		t.Fatal("a user revoked another user's approval")
+	}
+
+	p.endorse(t, "tx6", start.Add(time.Hour), carol, revoke("signed the wrong draft"))
+	file := p.file(t, "file1")
+	if contains(file.CurrentApprovals, "Org2MSP") || len(file.Approvals) != 1 {
+		t.Fatalf("after revoking: approvals %v by %d users, want only Org1's", file.CurrentApprovals, len(file.Approvals))
+	}
+	if len(file.Revocations) != 1 || file.Revocations[0].ClientID != carol.id || file.Revocations[0].Reason != "signed the wrong draft" {
+		t.Fatalf("revocations = %+v", file.Revocations)
+	}
+	if entry := p.auditEntry(t, "file1", "REVOKE"); !strings.Contains(entry.Details, "signed the wrong draft") {
+		t.Fatalf("REVOKE audit entry %q does not record the reason", entry.Details)
+	}
+
+	// The organization can approve again afterwards
+	p.endorse(t, "tx7", start.Add(2*time.Hour), carol, approve)
+	if !contains(p.file(t, "file1").CurrentApprovals, "Org2MSP") {
+		t.Fatal("Org2 could not approve again after revoking")
+	}
+
+	// Approvals recorded before per-user records only name the organization, any of its users may withdraw them
+	p.endorse(t, "tx8", start, alice, func(ctx contractapi.TransactionContextInterface) error {
+		legacy := models.File{ID: "legacy", Name: "old.pdf", Hash: "QmLegacy", Status: "PENDING", Version: 1,
+			RequiredOrgs: []string{"Org1MSP", "Org2MSP"}, CurrentApprovals: []string{"Org2MSP"}, EndorsementType: "ALL_ORGS"}
+		legacyJSON, _ := json.Marshal(legacy)
+		return ctx.GetStub().PutState(legacy.ID, legacyJSON)
+	})
+	p.endorse(t, "tx9", start, erin, func(ctx contractapi.TransactionContextInterface) error {
+		return RevokeApproval(ctx, "legacy", "approved by mistake")
+	})
+	if approvals := p.file(t, "legacy").CurrentApprovals; len(approvals) != 0 {
+		t.Fatalf("legacy approvals after revoking = %v, want none", approvals)
+	}
+}
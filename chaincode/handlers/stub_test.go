@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Client identity stub standing in for the certificate of the submitting user
+type fakeIdentity struct {
+	mspID string
+	id    string
+	attrs map[string]string // Certificate attributes, as issued by the Fabric CA
+	ous   []string          // Organizational units of the certificate subject, e.g. the node OU
+}
+
+func (f *fakeIdentity) GetID() (string, error)    { return f.id, nil }
+func (f *fakeIdentity) GetMSPID() (string, error) { return f.mspID, nil }
+func (f *fakeIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, ok := f.attrs[attrName]
+	return value, ok, nil
+}
+func (f *fakeIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	value, ok := f.attrs[attrName]
+	if !ok {
+		return fmt.Errorf("attribute %s not found", attrName)
+	}
+	if value != attrValue {
+		return fmt.Errorf("attribute %s is %s, not %s", attrName, value, attrValue)
+	}
+	return nil
+}
+func (f *fakeIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: f.ous}}, nil
+}
+
+// Mock stub that remembers every key written and the event set during the current transaction,
+// and keeps the history of every key like the peer's history database. Like the peer, it
+// refuses writes in a transaction that ran a paginated query and paginated queries in one that
+// wrote.
+type recordingStub struct {
+	*shimtest.MockStub
+	writes    map[string][]byte
+	event     *recordedEvent
+	history   map[string][]*queryresult.KeyModification
+	query     string // Last rich query run
+	wrote     bool
+	paginated bool
+}
+
+type recordedEvent struct {
+	name    string
+	payload []byte
+}
+
+// Fabric only runs paginated queries in read-only transactions
+func (s *recordingStub) write() error {
+	if s.paginated {
+		return fmt.Errorf("txid [%s]: transaction has already performed a paginated query, writes are not allowed", s.TxID)
+	}
+	s.wrote = true
+	return nil
+}
+
+func (s *recordingStub) paginate() error {
+	if s.wrote {
+		return fmt.Errorf("txid [%s]: paginated queries are only supported in read-only transactions", s.TxID)
+	}
+	s.paginated = true
+	return nil
+}
+
+func (s *recordingStub) PutState(key string, value []byte) error {
+	if err := s.write(); err != nil {
+		return err
+	}
+	s.writes[key] = value
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{
+		TxId:      s.TxID,
+		Value:     value,
+		Timestamp: s.TxTimestamp,
+	})
+	return s.MockStub.PutState(key, value)
+}
+
+func (s *recordingStub) DelState(key string) error {
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.MockStub.DelState(key)
+}
+
+func (s *recordingStub) PutPrivateData(collection string, key string, value []byte) error {
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.MockStub.PutPrivateData(collection, key, value)
+}
+
+func (s *recordingStub) DelPrivateData(collection string, key string) error {
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.MockStub.DelPrivateData(collection, key)
+}
+
+func (s *recordingStub) SetStateValidationParameter(key string, ep []byte) error {
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.MockStub.SetStateValidationParameter(key, ep)
+}
+
+// Returns the values written to a key, newest first like the peer does
+func (s *recordingStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := make([]*queryresult.KeyModification, 0, len(s.history[key]))
+	for i := len(s.history[key]) - 1; i >= 0; i-- {
+		modifications = append(modifications, s.history[key][i])
+	}
+	return &historyIterator{modifications: modifications}, nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool { return len(it.modifications) > 0 }
+func (it *historyIterator) Close() error  { return nil }
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if len(it.modifications) == 0 {
+		return nil, fmt.Errorf("no more history")
+	}
+	next := it.modifications[0]
+	it.modifications = it.modifications[1:]
+	return next, nil
+}
+
+// MockStub cannot paginate, so pages are cut here the way the peer cuts them: a page starts at
+// the bookmark and the next bookmark is the first key left out, or empty once none is
+func (s *recordingStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	if err := s.paginate(); err != nil {
+		return nil, nil, err
+	}
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	iterator, metadata := s.page(func(key string) bool { return strings.HasPrefix(key, prefix) }, pageSize, bookmark)
+	return iterator, metadata, nil
+}
+
+// Like Fabric, and unlike MockStub, it leaves composite keys out of range queries
+func (s *recordingStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	if err := s.paginate(); err != nil {
+		return nil, nil, err
+	}
+	iterator, metadata := s.page(simpleKeysIn(startKey, endKey), pageSize, bookmark)
+	return iterator, metadata, nil
+}
+
+func (s *recordingStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if strings.HasPrefix(startKey, "\x00") || strings.HasPrefix(endKey, "\x00") {
+		return nil, fmt.Errorf("range query keys must not start with a null character")
+	}
+	iterator, _ := s.page(simpleKeysIn(startKey, endKey), 0, "")
+	return iterator, nil
+}
+
+func simpleKeysIn(startKey, endKey string) func(key string) bool {
+	return func(key string) bool {
+		return !strings.HasPrefix(key, "\x00") && key >= startKey && (endKey == "" || key < endKey)
+	}
+}
+
+// Returns the matching keys from bookmark on, at most pageSize of them unless it is 0
+
+func (s *recordingStub) page(match func(key string) bool, pageSize int32, bookmark string) (*kvIterator, *peerpb.QueryResponseMetadata) {
+	var keys []string
+	for key := range s.State {
+		if match(key) && key >= bookmark {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	iterator := &kvIterator{}
+	metadata := &peerpb.QueryResponseMetadata{}
+	for i, key := range keys {
+		if pageSize > 0 && int32(i) == pageSize {
+			metadata.Bookmark = key
+			break
+		}
+		iterator.kvs = append(iterator.kvs, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+	metadata.FetchedRecordsCount = int32(len(iterator.kvs))
+	return iterator, metadata
+}
+
+// MockStub has no CouchDB, so a rich query remembers its query string and returns every simple key
+func (s *recordingStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	s.query = query
+	return s.GetStateByRangeWithPagination("", "", pageSize, bookmark)
+}
+
+type kvIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *kvIterator) HasNext() bool { return len(it.kvs) > 0 }
+func (it *kvIterator) Close() error  { return nil }
+func (it *kvIterator) Next() (*queryresult.KV, error) {
+	if len(it.kvs) == 0 {
+		return nil, fmt.Errorf("no more keys")
+	}
+	next := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return next, nil
+}
+
+// Keeps the event like Fabric does, where a later call replaces an earlier one.
+// MockStub would queue it on a buffered channel nobody drains.
+func (s *recordingStub) SetEvent(name string, payload []byte) error {
+	s.event = &recordedEvent{name: name, payload: payload}
+	return nil
+}
+
+// A simulated endorsing peer with its own copy of the world state
+type peer struct {
+	stub *recordingStub
+}
+
+// Organizations whose admins have registered them on every simulated peer
+var channelMembers = []string{"Org1MSP", "Org2MSP", "Org3MSP"}
+
+// Starts a peer on a channel where every organization in channelMembers has registered
+func newPeer(name string) *peer {
+	p := &peer{stub: &recordingStub{
+		MockStub: shimtest.NewMockStub(name, nil),
+		history:  map[string][]*queryresult.KeyModification{},
+	}}
+
+	joined := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, mspID := range channelMembers {
+		admin := &fakeIdentity{mspID: mspID, id: "x509::CN=admin::CN=ca." + mspID, attrs: map[string]string{"hf.Type": "admin"}}
+		if err := p.invoke("join-"+mspID, joined, admin, RegisterOrganization); err != nil {
+			panic(fmt.Sprintf("failed to register %s on %s: %v", mspID, name, err))
+		}
+	}
+	return p
+}
+
+// Runs a handler as transaction txID, submitted at txTime by identity.
+// The mock stub has no rollback, so writes made before a failure stay in the state.
+func (p *peer) invoke(txID string, txTime time.Time, identity *fakeIdentity, handler func(contractapi.TransactionContextInterface) error) error {
+	p.stub.writes = map[string][]byte{}
+	p.stub.event = nil
+	p.stub.wrote, p.stub.paginated = false, false
+	p.stub.MockTransactionStart(txID)
+	p.stub.TxTimestamp = timestamppb.New(txTime)
+	defer p.stub.MockTransactionEnd(txID)
+
+	ctx := new(TransactionContext)
+	ctx.SetStub(p.stub)
+	ctx.SetClientIdentity(identity)
+
+	return handler(ctx)
+}
+
+// Like invoke, but fails the test on error and returns the transaction's write set
+func (p *peer) endorse(t *testing.T, txID string, txTime time.Time, identity *fakeIdentity, handler func(contractapi.TransactionContextInterface) error) map[string][]byte {
+	t.Helper()
+
+	if err := p.invoke(txID, txTime, identity, handler); err != nil {
+		t.Fatalf("transaction %s failed on %s: %v", txID, p.stub.Name, err)
+	}
+
+	return p.stub.writes
+}
+
+// Runs BackfillHashIndex until it reports the hash index complete
+func (p *peer) backfillHashIndex(t *testing.T, txTime time.Time) {
+	t.Helper()
+	p.backfill(t, "backfill-hash", txTime, BackfillHashIndex)
+}
+
+func (p *peer) backfillAuditIndexes(t *testing.T, txTime time.Time) {
+	t.Helper()
+	p.backfill(t, "backfill-audit", txTime, BackfillAuditIndexes)
+}
+
+// Runs a backfill transaction, a few records at a time, until it reports complete
+func (p *peer) backfill(t *testing.T, txPrefix string, txTime time.Time, run func(contractapi.TransactionContextInterface, int32) (string, error)) {
+	t.Helper()
+
+	for i := 0; ; i++ {
+		var progress models.BackfillProgress
+		p.endorse(t, fmt.Sprintf("%s-%d", txPrefix, i), txTime, &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=admin::CN=ca.org1"},
+			func(ctx contractapi.TransactionContextInterface) error {
+				progressJSON, err := run(ctx, 2)
+				if err != nil {
+					return err
+				}
+				return json.Unmarshal([]byte(progressJSON), &progress)
+			})
+		if progress.Complete {
+			return
+		}
+	}
+}
+
+// Reads a file straight from the peer's world state
+func (p *peer) file(t *testing.T, id string) *models.File {
+	t.Helper()
+
+	fileJSON, ok := p.stub.State[id]
+	if !ok {
+		t.Fatalf("file %s not found on %s", id, p.stub.Name)
+	}
+
+	var file models.File
+	if err := json.Unmarshal(fileJSON, &file); err != nil {
+		t.Fatalf("failed to unmarshal file %s: %v", id, err)
+	}
+	return &file
+}
+
+// Returns the latest audit entry of a file for an action
+func (p *peer) auditEntry(t *testing.T, fileID string, action string) *models.AuditLog {
+	t.Helper()
+
+	var latest *models.AuditLog
+	prefix, _ := p.stub.CreateCompositeKey("audit", []string{fileID})
+	for key, value := range p.stub.State {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		var entry models.AuditLog
+		if err := json.Unmarshal(value, &entry); err != nil {
+			t.Fatalf("failed to unmarshal audit entry %s: %v", key, err)
+		}
+		if entry.Action == action && (latest == nil || entry.Sequence > latest.Sequence) {
+			latest = &entry
+		}
+	}
+	if latest == nil {
+		t.Fatalf("no %s audit entry for file %s on %s", action, fileID, p.stub.Name)
+	}
+	return latest
+}
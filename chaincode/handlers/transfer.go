@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key index of pending transfers by receiving organization.
+// Format: transfer~msp~id so an organization's incoming transfers are a single prefix scan
+const transferIndex = "transfer~msp~id"
+
+// Reports whether the caller owns the file. Files registered before transfers only
+// record the owning organization, so any of its users counts as the owner.
+func isOwner(ctx contractapi.TransactionContextInterface, file *models.File) (bool, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if file.OwnerMSP != mspID {
+		return false, nil
+	}
+	if file.OwnerID == "" {
+		return true, nil
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client ID: %v", err)
+	}
+	return file.OwnerID == clientID, nil
+}
+
+// Offers a file to another organization. Only the owner can propose a transfer, and a new
+// proposal replaces any earlier one that has not been accepted yet.
+func ProposeTransfer(ctx contractapi.TransactionContextInterface, id string, newOwnerMSP string) error {
+	if newOwnerMSP == "" {
+		return fmt.Errorf("the receiving organization is required")
+	}
+
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("file does not exist: %s", id)
+	}
+	if err := requireLive(file); err != nil {
+		return err
+	}
+	if file.OwnerMSP == "" {
+		return fmt.Errorf("file %s predates ownership records and has no owning organization", id)
+	}
+
+	owner, err := isOwner(ctx, file)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		return fmt.Errorf("only the owner of file %s can transfer it", id)
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if file.PendingTransfer != nil {
+		if err := deleteTransferIndex(ctx, file.PendingTransfer.ToMSP, id); err != nil {
+			return err
+		}
+	}
+
+	file.PendingTransfer = &models.OwnershipTransfer{
+		FileID:     id,
+		FileName:   file.Name,
+		FromMSP:    file.OwnerMSP,
+		FromID:     clientID,
+		ToMSP:      newOwnerMSP,
+		ProposedAt: txTime.Format(time.RFC3339),
+	}
+
+	if err := putFile(ctx, file); err != nil {
+		return err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(transferIndex, []string{newOwnerMSP, id})
+	if err != nil {
+		return fmt.Errorf("failed to create transfer index key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+		return fmt.Errorf("failed to index transfer: %v", err)
+	}
+
+	details := fmt.Sprintf("Organization %s offered file %s to %s", file.OwnerMSP, file.Name, newOwnerMSP)
+	if err := CreateAuditLog(ctx, id, "PROPOSE_TRANSFER", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileTransferProposed, file)
+}
+
+// Accepts a transfer offered to the caller's organization. The accepting user becomes the owner.
+func AcceptTransfer(ctx contractapi.TransactionContextInterface, id string) error {
+	file, transfer, err := incomingTransfer(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := requireLive(file); err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+	if transfer.FromMSP == transfer.ToMSP && clientID == transfer.FromID {
+		return fmt.Errorf("the proposer of a transfer cannot accept it")
+	}
+
+	// The owner name of a private file stays in its collection, like at registration
+	if file.PrivateCollection != "" {
+		if err := setPrivateOwner(ctx, file, transfer.ToMSP); err != nil {
+			return err
+		}
+	} else {
+		file.Owner = transfer.ToMSP
+	}
+	file.OwnerMSP = transfer.ToMSP
+	file.OwnerID = clientID
+	file.PendingTransfer = nil
+
+	// The new owner can always read the file; the previous owner keeps whatever reader rules name it
+	file.Readers = withOrgReaders(file.Readers, transfer.ToMSP)
+
+	if err := putFile(ctx, file); err != nil {
+		return err
+	}
+	if err := deleteTransferIndex(ctx, transfer.ToMSP, id); err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("Organization %s accepted file %s from %s", transfer.ToMSP, file.Name, transfer.FromMSP)
+	if err := CreateAuditLog(ctx, id, "ACCEPT_TRANSFER", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileTransferAccepted, file)
+}
+
+// Turns down a transfer offered to the caller's organization. The file stays with its owner.
+func DeclineTransfer(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	file, transfer, err := incomingTransfer(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	file.PendingTransfer = nil
+
+	if err := putFile(ctx, file); err != nil {
+		return err
+	}
+	if err := deleteTransferIndex(ctx, transfer.ToMSP, id); err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("Organization %s declined file %s from %s", transfer.ToMSP, file.Name, transfer.FromMSP)
+	if reason != "" {
+		details += fmt.Sprintf(": %s", reason)
+	}
+	if err := CreateAuditLog(ctx, id, "DECLINE_TRANSFER", details); err != nil {
+		return err
+	}
+
+	return emitFileEvent(ctx, models.EventFileTransferDeclined, file)
+}
+
+// Returns the transfers offered to the caller's organization as JSON
+func GetIncomingTransfers(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transferIndex, []string{mspID})
+	if err != nil {
+		return "", fmt.Errorf("failed to query transfer index: %v", err)
+	}
+	defer iterator.Close()
+
+	transfers := []models.OwnershipTransfer{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return "", fmt.Errorf("failed to iterate transfer index: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return "", fmt.Errorf("failed to split transfer index key: %v", err)
+		}
+		if len(keyParts) != 2 {
+			continue // Skip malformed index entries
+		}
+
+		file, err := readFile(ctx, keyParts[1])
+		if err != nil {
+			return "", err
+		}
+		if file == nil || file.PendingTransfer == nil || file.PendingTransfer.ToMSP != mspID {
+			continue // Stale index entry
+		}
+
+		transfers = append(transfers, *file.PendingTransfer)
+	}
+
+	transfersJSON, err := json.Marshal(transfers)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal transfers: %v", err)
+	}
+
+	return string(transfersJSON), nil
+}
+
+// Returns a file and its pending transfer, provided the transfer is offered to the caller's organization
+func incomingTransfer(ctx contractapi.TransactionContextInterface, id string) (*models.File, *models.OwnershipTransfer, error) {
+	file, err := readFile(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file == nil {
+		return nil, nil, fmt.Errorf("file does not exist: %s", id)
+	}
+	if file.PendingTransfer == nil {
+		return nil, nil, fmt.Errorf("file %s has no pending transfer", id)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if file.PendingTransfer.ToMSP != mspID {
+		return nil, nil, fmt.Errorf("file %s is not being transferred to organization %s", id, mspID)
+	}
+
+	return file, file.PendingTransfer, nil
+}
+
+// Removes a file from an organization's incoming transfers
+func deleteTransferIndex(ctx contractapi.TransactionContextInterface, mspID string, id string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(transferIndex, []string{mspID, id})
+	if err != nil {
+		return fmt.Errorf("failed to create transfer index key: %v", err)
+	}
+	return ctx.GetStub().DelState(indexKey)
+}
+
+// Writes an updated file back to the world state
+func putFile(ctx contractapi.TransactionContextInterface, file *models.File) error {
+	fileJSON, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated file: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(file.ID, fileJSON); err != nil {
+		return fmt.Errorf("failed to update file state: %v", err)
+	}
+	return nil
+}
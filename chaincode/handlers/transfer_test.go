@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestOwnershipTransferNeedsAcceptance(t *testing.T) {
+	owner := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=owner::CN=ca.org1"}
+	colleague := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=colleague::CN=ca.org1"}
+	receiver := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=receiver::CN=ca.org2"}
+	start := time.Date(2025, 8, 4, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "contract.pdf", "QmHash", "Org1", `{}`, "",
+			`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, "", "", "")
+		return err
+	})
+	if got := p.file(t, "file1"); got.OwnerMSP != "Org1MSP" || got.OwnerID != owner.id {
+		t.Fatalf("file1 owned by %s/%s, want Org1MSP/%s", got.OwnerMSP, got.OwnerID, owner.id)
+	}
+
+	propose := func(ctx contractapi.TransactionContextInterface) error {
+		return ProposeTransfer(ctx, "file1", "Org2MSP")
+	}
+	accept := func(ctx contractapi.TransactionContextInterface) error {
+		return AcceptTransfer(ctx, "file1")
+	}
+	incoming := func(txID string, identity *fakeIdentity) []models.OwnershipTransfer {
+		var transfers []models.OwnershipTransfer
+		p.endorse(t, txID, start, identity, func(ctx contractapi.TransactionContextInterface) error {
+			transfersJSON, err := GetIncomingTransfers(ctx)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(transfersJSON), &transfers)
+		})
+		return transfers
+	}
+
+	// Ownership belongs to the user, not their whole organization
+	if err := p.invoke("tx2", start, colleague, propose); err == nil {
+		t.Fatal("a colleague of the owner proposed a transfer")
+	}
+	if err := p.invoke("tx3", start, receiver, accept); err == nil {
+		t.Fatal("accepted a transfer that was never proposed")
+	}
+
+	// Org2 only gets to read the file while it is offered to it
+	p.endorse(t, "tx4", start, owner, propose)
+	p.endorse(t, "tx4a", start, receiver, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := GetFileByID(ctx, "file1")
+		return err
+	})
+	if transfers := incoming("tx5", receiver); len(transfers) != 1 || transfers[0].FileID != "file1" {
+		t.Fatalf("Org2 sees incoming transfers %+v, want file1", transfers)
+	}
+	if transfers := incoming("tx6", owner); len(transfers) != 0 {
+		t.Fatalf("Org1 sees incoming transfers %+v, want none", transfers)
+	}
+
+	// Declining leaves the file with its owner
+	p.endorse(t, "tx7", start, receiver, func(ctx contractapi.TransactionContextInterface) error {
+		return DeclineTransfer(ctx, "file1", "not ours")
+	})
+	if got := p.file(t, "file1"); got.OwnerMSP != "Org1MSP" || got.PendingTransfer != nil {
+		t.Fatalf("declined transfer left owner %s and pending transfer %+v", got.OwnerMSP, got.PendingTransfer)
+	}
+	if transfers := incoming("tx8", receiver); len(transfers) != 0 {
+		t.Fatalf("declined transfer is still incoming: %+v", transfers)
+	}
+	if err := p.invoke("tx8a", start, receiver, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := GetFileByID(ctx, "file1")
+		return err
+	}); err == nil {
+		t.Fatal("Org2 still reads the file after declining it")
+	}
+
+	p.endorse(t, "tx9", start, owner, propose)
+	if err := p.invoke("tx10", start, owner, accept); err == nil {
+		t.Fatal("the proposing organization accepted its own transfer")
+	}
+	p.endorse(t, "tx11", start.Add(time.Hour), receiver, accept)
+
+	got := p.file(t, "file1")
+	if got.OwnerMSP != "Org2MSP" || got.OwnerID != receiver.id || got.PendingTransfer != nil {
+		t.Fatalf("after acceptance file1 is owned by %s/%s with pending transfer %+v", got.OwnerMSP, got.OwnerID, got.PendingTransfer)
+	}
+
+	// The previous owner lost the right to transfer it
+	if err := p.invoke("tx12", start, owner, propose); err == nil {
+		t.Fatal("the previous owner proposed a transfer")
+	}
+}
+
+func TestTransferOfPrivateFileKeepsTheOwnerPrivate(t *testing.T) {
+	owner := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=owner::CN=ca.org1"}
+	receiver := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=receiver::CN=ca.org2"}
+	start := time.Date(2025, 8, 4, 9, 0, 0, 0, time.UTC)
+
+	p := newPeer("peer0.org1")
+	p.stub.TransientMap = map[string][]byte{transientOwnerKey: []byte("Acme Legal"), transientMetadataKey: []byte(`{}`)}
+	p.endorse(t, "tx1", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "nda.pdf", "QmHash", "", "", "",
+			`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ALL_ORGS","private":true}`, "", "", "")
+		return err
+	})
+	p.stub.TransientMap = nil
+
+	p.endorse(t, "tx2", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		return ProposeTransfer(ctx, "file1", "Org2MSP")
+	})
+	p.endorse(t, "tx3", start, receiver, func(ctx contractapi.TransactionContextInterface) error {
+		return AcceptTransfer(ctx, "file1")
+	})
+
+	file := p.file(t, "file1")
+	if file.Owner != "" || file.OwnerMSP != "Org2MSP" {
+		t.Fatalf("public owner %q with MSP %s, want no owner name and Org2MSP", file.Owner, file.OwnerMSP)
+	}
+
+	var details models.FilePrivateDetails
+	p.endorse(t, "tx4", start, receiver, func(ctx contractapi.TransactionContextInterface) error {
+		detailsJSON, err := GetFilePrivateDetails(ctx, "file1")
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(detailsJSON), &details)
+	})
+	if details.Owner != "Org2MSP" {
+		t.Fatalf("private owner = %q, want Org2MSP", details.Owner)
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Returns the transaction timestamp chosen by the client in the proposal.
+// Every endorsing peer sees the same value, unlike time.Now(), so anything written to the
+// ledger must take its time from here or the peers' read/write sets will not match.
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return ts.AsTime().UTC(), nil
+}
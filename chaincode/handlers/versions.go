@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key index linking a version to each of its successors.
+// Format: successor~previousID~id so all successors of a version can be found by prefix
+const successorIndex = "successor~previousID~id"
+
+// How RegisterFile treats a previous version that already has a successor
+const (
+	VersionLinear = "LINEAR" // Fail with a VersionConflict
+	VersionFork   = "FORK"   // Branch the chain deliberately
+)
+
+// Validates a version policy argument, defaulting to LINEAR
+func parseVersionPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return VersionLinear, nil
+	case VersionLinear, VersionFork:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid version policy: %s", policy)
+	}
+}
+
+// Records id as a successor of previousID in the forward version index
+func putSuccessorIndex(ctx contractapi.TransactionContextInterface, previousID string, id string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(successorIndex, []string{previousID, id})
+	if err != nil {
+		return fmt.Errorf("failed to create successor index key: %v", err)
+	}
+
+	// Only the key is needed, CouchDB and LevelDB both reject nil values
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// Returns the IDs of every version registered with id as its previous version
+func getSuccessorIDs(ctx contractapi.TransactionContextInterface, id string) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(successorIndex, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query successor index: %v", err)
+	}
+	defer iterator.Close()
+
+	var ids []string
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate successor index: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split successor index key: %v", err)
+		}
+		if len(keyParts) != 2 {
+			continue // Skip malformed index entries
+		}
+
+		ids = append(ids, keyParts[1])
+	}
+
+	return ids, nil
+}
+
+// Reads and unmarshals a single file from the world state, returning nil if it does not exist
+func readFile(ctx contractapi.TransactionContextInterface, id string) (*models.File, error) {
+	fileJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if fileJSON == nil {
+		return nil, nil
+	}
+
+	var file models.File
+	if err := json.Unmarshal(fileJSON, &file); err != nil {
+		return nil, fmt.Errorf("error unmarshaling file %s: %v", id, err)
+	}
+
+	return &file, nil
+}
+
+// Retrieve the versions registered directly on top of a file that the caller can read.
+// More than one result means the version chain branches at this file.
+func GetNextVersions(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	successorIDs, err := getSuccessorIDs(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	versions := []models.File{}
+	for _, successorID := range successorIDs {
+		file, err := readFile(ctx, successorID)
+		if err != nil {
+			return "", err
+		}
+		if file == nil || !caller.canRead(file) {
+			continue // Index entry without a file, or one the caller may not see
+		}
+		versions = append(versions, *file)
+	}
+
+	versionsJSON, err := json.Marshal(versions)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal next versions: %v", err)
+	}
+
+	return string(versionsJSON), nil
+}
+
+// Retrieve the newest version reachable from a file by walking the chain forward.
+// When the chain branches, the highest version wins, then the most recent timestamp, then the ID.
+func GetLatestVersion(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	start, err := readFile(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if start == nil {
+		return "", nil // Same convention as GetFileByID for unknown files
+	}
+
+	caller, err := callerReader(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !caller.canRead(start) {
+		return "", fmt.Errorf("access denied: organization %s cannot read file %s", caller.mspID, id)
+	}
+
+	latest := *start
+	visited := map[string]bool{id: true}
+	queue := []string{id}
+
+	for len(queue) > 0 {
+		currentID := queue[0]
+		queue = queue[1:]
+
+		successorIDs, err := getSuccessorIDs(ctx, currentID)
+		if err != nil {
+			return "", err
+		}
+
+		for _, successorID := range successorIDs {
+			if visited[successorID] {
+				continue
+			}
+			visited[successorID] = true
+
+			file, err := readFile(ctx, successorID)
+			if err != nil {
+				return "", err
+			}
+			if file == nil {
+				continue
+			}
+
+			// Unreadable versions are walked through but never returned
+			if caller.canRead(file) && isNewerVersion(*file, latest) {
+				latest = *file
+			}
+			queue = append(queue, successorID)
+		}
+	}
+
+	latestJSON, err := json.Marshal(latest)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal latest version: %v", err)
+	}
+
+	return string(latestJSON), nil
+}
+
+// Reports whether candidate should replace current as the latest version
+func isNewerVersion(candidate models.File, current models.File) bool {
+	if candidate.Version != current.Version {
+		return candidate.Version > current.Version
+	}
+	if candidate.Timestamp != current.Timestamp {
+		return candidate.Timestamp > current.Timestamp
+	}
+	return candidate.ID > current.ID
+}
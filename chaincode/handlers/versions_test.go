@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"dltfm/pkg/models"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestNewVersionsNeedAnEditorAndTheLatestParent(t *testing.T) {
+	owner := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=owner::CN=ca.org1"}
+	editor := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=editor::CN=ca.org2", attrs: map[string]string{"role": "editor"}}
+	stranger := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=stranger::CN=ca.org2"}
+	start := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+
+	register := func(id string, previousID string, versionPolicy string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, "spec.md", "Qm"+id, "Org1", `{}`, previousID,
+				`{"requiredOrgs":["Org1MSP","Org2MSP"],"policyType":"ANY_ORG"}`, "", "", versionPolicy)
+			return err
+		}
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, owner, register("v1", "", ""))
+
+	if err := p.invoke("tx2", start, stranger, register("v2", "v1", "")); err == nil {
+		t.Fatal("a user who is neither owner nor editor registered a new version")
+	}
+
+	p.endorse(t, "tx3", start, owner, func(ctx contractapi.TransactionContextInterface) error {
+		return UpdateEditors(ctx, "v1", `[{"mspId":"Org2MSP","attribute":"role","value":"editor"}]`)
+	})
+	p.endorse(t, "tx4", start, editor, register("v2", "v1", ""))
+
+	// The chain keeps its owner and editors
+	v2 := p.file(t, "v2")
+	if v2.OwnerMSP != "Org1MSP" || v2.OwnerID != owner.id || len(v2.Editors) != 1 {
+		t.Fatalf("v2 owned by %s/%s with editors %v, want the owner and editors of v1", v2.OwnerMSP, v2.OwnerID, v2.Editors)
+	}
+
+	// A second version on top of v1 is stale
+	err := p.invoke("tx5", start, owner, register("v2b", "v1", ""))
+	var conflict *models.VersionConflict
+	if !errors.As(err, &conflict) {
+		t.Fatalf("registering on a stale parent returned %v, want a version conflict", err)
+	}
+	if conflict.PreviousID != "v1" || len(conflict.Successors) != 1 || conflict.Successors[0] != "v2" {
+		t.Fatalf("conflict = %+v, want v1 already succeeded by v2", conflict)
+	}
+	if !models.IsVersionConflict(errors.New("endorse error: " + err.Error())) {
+		t.Fatal("a version conflict message is not recognized once wrapped")
+	}
+
+	// Unless the caller forks on purpose
+	p.endorse(t, "tx6", start, owner, register("v2b", "v1", VersionFork))
+	if got := p.file(t, "v2b").Version; got != 2 {
+		t.Fatalf("fork has version %d, want 2", got)
+	}
+}
+
+func TestVersionChainQueries(t *testing.T) {
+	owner := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=owner::CN=ca.org1"}
+	partner := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=partner::CN=ca.org2"}
+	start := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+
+	register := func(id string, previousID string, readers string, versionPolicy string) func(contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			_, err := RegisterFile(ctx, id, "plan.md", "Qm"+id, "Org1", `{}`, previousID,
+				`{"requiredOrgs":["Org1MSP"],"policyType":"ANY_ORG"}`, "", readers, versionPolicy)
+			return err
+		}
+	}
+	shared := `[{"mspId":"Org2MSP"}]`
+
+	// v1 -> v2 -> v3 -> v4, with v2b forked off v1 and v3 kept from Org2
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, owner, register("v1", "", shared, ""))
+	p.endorse(t, "tx2", start.Add(time.Hour), owner, register("v2", "v1", shared, ""))
+	p.endorse(t, "tx3", start.Add(2*time.Hour), owner, register("v3", "v2", `[]`, ""))
+	p.endorse(t, "tx4", start.Add(3*time.Hour), owner, register("v4", "v3", shared, ""))
+	p.endorse(t, "tx5", start.Add(4*time.Hour), owner, register("v2b", "v1", shared, VersionFork))
+
+	next := func(txID string, caller *fakeIdentity, id string) []string {
+		var versions []models.File
+		p.endorse(t, txID, start, caller, func(ctx contractapi.TransactionContextInterface) error {
+			versionsJSON, err := GetNextVersions(ctx, id)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(versionsJSON), &versions)
+		})
+		ids := []string{}
+		for _, version := range versions {
+			ids = append(ids, version.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	latest := func(txID string, caller *fakeIdentity, id string) string {
+		var latestJSON string
+		p.endorse(t, txID, start, caller, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			latestJSON, err = GetLatestVersion(ctx, id)
+			return err
+		})
+		if latestJSON == "" {
+			return ""
+		}
+		var file models.File
+		if err := json.Unmarshal([]byte(latestJSON), &file); err != nil {
+			t.Fatal(err)
+		}
+		return file.ID
+	}
+
+	if got := next("tx6", owner, "v1"); strings.Join(got, ",") != "v2,v2b" {
+		t.Fatalf("successors of v1 = %v, want v2 and v2b", got)
+	}
+	if got := next("tx7", owner, "v4"); len(got) != 0 {
+		t.Fatalf("successors of v4 = %v, want none", got)
+	}
+	if got := next("tx8", partner, "v2"); len(got) != 0 {
+		t.Fatalf("Org2 sees successors %v of v2, want v3 hidden", got)
+	}
+
+	// The longest branch wins, and a branch is followed from any version on it
+	if got := latest("tx9", owner, "v1"); got != "v4" {
+		t.Fatalf("latest version of v1 = %s, want v4", got)
+	}
+	if got := latest("tx10", owner, "v2b"); got != "v2b" {
+		t.Fatalf("latest version of v2b = %s, want itself", got)
+	}
+
+	// Versions the caller cannot read are walked through but never returned
+	if got := latest("tx11", partner, "v1"); got != "v4" {
+		t.Fatalf("latest version of v1 for Org2 = %s, want v4 past the unreadable v3", got)
+	}
+	if got := latest("tx12", owner, "missing"); got != "" {
+		t.Fatalf("latest version of an unknown file = %s, want nothing", got)
+	}
+	if err := p.invoke("tx13", start, partner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := GetLatestVersion(ctx, "v3")
+		return err
+	}); err == nil {
+		t.Fatal("Org2 walked the chain from a version it cannot read")
+	}
+}
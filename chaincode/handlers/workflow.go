@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dltfm/pkg/models"
+	"dltfm/pkg/models/policy"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key prefix for workflow definitions, keeping them out of file range scans
+const workflowPrefix = "workflow"
+
+// Policy type for files approved stage by stage through a stored workflow
+const PolicyTypeWorkflow = "WORKFLOW"
+
+// Stores a new workflow definition. Files reference workflows by ID, so a
+// definition cannot be replaced once created; publish a new ID instead.
+func CreateWorkflow(ctx contractapi.TransactionContextInterface, id string, name string, stagesJSON string) error {
+	if id == "" {
+		return fmt.Errorf("workflow ID is required")
+	}
+
+	var stages []models.WorkflowStage
+	if err := json.Unmarshal([]byte(stagesJSON), &stages); err != nil {
+		return fmt.Errorf("invalid workflow stages: %v", err)
+	}
+	if len(stages) == 0 {
+		return fmt.Errorf("workflow %s must have at least one stage", id)
+	}
+
+	// Store each stage policy in canonical form so files copy a known-good expression
+	for i := range stages {
+		if stages[i].Name == "" {
+			return fmt.Errorf("stage %d of workflow %s has no name", i+1, id)
+		}
+		parsed, err := policy.Parse(stages[i].Policy)
+		if err != nil {
+			return fmt.Errorf("invalid policy for stage %d (%s): %v", i+1, stages[i].Name, err)
+		}
+		if err := requireChannelMembers(ctx, parsed.Orgs()); err != nil {
+			return fmt.Errorf("invalid policy for stage %d (%s): %v", i+1, stages[i].Name, err)
+		}
+		stages[i].Policy = parsed.String()
+	}
+
+	existing, err := readWorkflow(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("workflow %s already exists", id)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	workflow := models.Workflow{
+		ID:        id,
+		Name:      name,
+		Stages:    stages,
+		CreatedBy: mspID,
+		Timestamp: txTime.Format(time.RFC3339),
+	}
+
+	workflowJSON, err := json.Marshal(workflow)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(workflowPrefix, []string{id})
+	if err != nil {
+		return fmt.Errorf("failed to create workflow key: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(key, workflowJSON); err != nil {
+		return fmt.Errorf("failed to save workflow: %v", err)
+	}
+
+	return emitLedgerEvent(ctx, models.EventWorkflowCreated, id, fmt.Sprintf("%d stages", len(stages)))
+}
+
+// Returns a workflow definition as JSON
+func GetWorkflow(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	workflow, err := readWorkflow(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if workflow == nil {
+		return "", fmt.Errorf("workflow does not exist: %s", id)
+	}
+
+	workflowJSON, err := json.Marshal(workflow)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal workflow: %v", err)
+	}
+
+	return string(workflowJSON), nil
+}
+
+// Loads a workflow from state, returning nil if it does not exist
+func readWorkflow(ctx contractapi.TransactionContextInterface, id string) (*models.Workflow, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(workflowPrefix, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workflow key: %v", err)
+	}
+
+	workflowJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow: %v", err)
+	}
+	if workflowJSON == nil {
+		return nil, nil
+	}
+
+	var workflow models.Workflow
+	if err := json.Unmarshal(workflowJSON, &workflow); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow: %v", err)
+	}
+
+	return &workflow, nil
+}
+
+// Returns the workflow and the policy of its first stage for a new registration
+func startWorkflow(ctx contractapi.TransactionContextInterface, workflowID string) (*models.Workflow, *policy.Policy, error) {
+	if workflowID == "" {
+		return nil, nil, fmt.Errorf("policy type %s requires a workflow ID", PolicyTypeWorkflow)
+	}
+
+	workflow, err := readWorkflow(ctx, workflowID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if workflow == nil {
+		return nil, nil, fmt.Errorf("workflow does not exist: %s", workflowID)
+	}
+
+	firstStage, err := policy.Parse(workflow.Stages[0].Policy)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stored policy of workflow %s is invalid: %v", workflowID, err)
+	}
+
+	return workflow, firstStage, nil
+}
+
+// Lists every organization named in any stage of the workflow
+func workflowOrgs(workflow *models.Workflow) ([]string, error) {
+	var orgs []string
+	for _, stage := range workflow.Stages {
+		stagePolicy, err := policy.Parse(stage.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("stored policy of workflow %s is invalid: %v", workflow.ID, err)
+		}
+		orgs = append(orgs, stagePolicy.Orgs()...)
+	}
+	return uniqueOrgs(orgs), nil
+}
+
+// Updates the file once the active policy is met. Files without a workflow are approved;
+// files in a workflow complete their current stage and either move on to the next one,
+// which starts without approvals, or are approved after the last stage.
+func settleApprovals(ctx contractapi.TransactionContextInterface, file *models.File, approvalPolicy *policy.Policy, txTime time.Time) error {
+	if !approvalPolicy.Evaluate(approvalSigners(file)) {
+		return nil
+	}
+
+	if file.WorkflowID == "" {
+		file.Status = "APPROVED"
+		return nil
+	}
+
+	workflow, err := readWorkflow(ctx, file.WorkflowID)
+	if err != nil {
+		return err
+	}
+	if workflow == nil {
+		return fmt.Errorf("workflow %s of file %s does not exist", file.WorkflowID, file.ID)
+	}
+	if file.CurrentStage < 1 || file.CurrentStage > len(workflow.Stages) {
+		return fmt.Errorf("file %s is at stage %d, workflow %s has %d stages", file.ID, file.CurrentStage, workflow.ID, len(workflow.Stages))
+	}
+
+	completed := workflow.Stages[file.CurrentStage-1]
+	file.CompletedStages = append(file.CompletedStages, models.StageCompletion{
+		Stage:       file.CurrentStage,
+		Name:        completed.Name,
+		Approvals:   file.Approvals,
+		CompletedAt: txTime.Format(time.RFC3339),
+	})
+
+	var details string
+	if file.CurrentStage == len(workflow.Stages) {
+		file.Status = "APPROVED"
+		details = fmt.Sprintf("Stage %d (%s) of workflow %s completed, file %s approved", file.CurrentStage, completed.Name, workflow.ID, file.Name)
+	} else {
+		next := workflow.Stages[file.CurrentStage]
+		nextPolicy, err := policy.Parse(next.Policy)
+		if err != nil {
+			return fmt.Errorf("stored policy of workflow %s is invalid: %v", workflow.ID, err)
+		}
+
+		file.CurrentStage++
+		file.EndorsementPolicy = nextPolicy.String()
+		file.RequiredOrgs = nextPolicy.Orgs()
+		file.Approvals = nil
+		file.CurrentApprovals = []string{}
+
+		// Hand the file key over to the next stage's organizations
+		if err := setFileEndorsementPolicy(ctx, file); err != nil {
+			return err
+		}
+
+		details = fmt.Sprintf("Stage %d (%s) of workflow %s completed, stage %d (%s) is now active", file.CurrentStage-1, completed.Name, workflow.ID, file.CurrentStage, next.Name)
+	}
+
+	return CreateAuditLog(ctx, file.ID, "STAGE_ADVANCE", details)
+}
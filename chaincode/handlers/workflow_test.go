@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestWorkflowAdvancesStageByStage(t *testing.T) {
+	alice := &fakeIdentity{mspID: "Org1MSP", id: "x509::CN=alice::CN=ca.org1"}
+	bob := &fakeIdentity{mspID: "Org2MSP", id: "x509::CN=bob::CN=ca.org2"}
+	carol := &fakeIdentity{mspID: "Org3MSP", id: "x509::CN=carol::CN=ca.org3"}
+	start := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+	createWorkflow := func(ctx contractapi.TransactionContextInterface) error {
+		return CreateWorkflow(ctx, "contract-review", "Contract review", `[
+			{"name": "technical review", "policy": "'Org1MSP'"},
+			{"name": "legal", "policy": "'Org2MSP'"},
+			{"name": "final sign-off", "policy": "AND('Org1MSP', 'Org3MSP')"}
+		]`)
+	}
+	register := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := RegisterFile(ctx, "file1", "contract.pdf", "QmHash", "Org1", `{"size":42,"type":"application/pdf"}`, "",
+			`{"policyType":"WORKFLOW","workflow":"contract-review"}`, "", "", "")
+		return err
+	}
+	approve := func(ctx contractapi.TransactionContextInterface) error {
+		return ApproveFile(ctx, "file1")
+	}
+
+	p := newPeer("peer0.org1")
+	p.endorse(t, "tx1", start, alice, createWorkflow)
+	if err := p.invoke("tx2", start, bob, createWorkflow); err == nil {
+		t.Fatal("redefining an existing workflow succeeded")
+	}
+
+	// The submitter's approval completes the technical review straight away
+	p.endorse(t, "tx3", start.Add(time.Minute), alice, register)
+	file := p.file(t, "file1")
+	if file.CurrentStage != 2 || file.Status != "PENDING" {
+		t.Fatalf("after registration: stage %d status %s, want stage 2 PENDING", file.CurrentStage, file.Status)
+	}
+
+	// Org3 only has a say in the final stage
+	if err := p.invoke("tx4", start.Add(2*time.Minute), carol, approve); err == nil {
+		t.Fatal("approval outside the active stage succeeded")
+	}
+
+	p.endorse(t, "tx5", start.Add(3*time.Minute), bob, approve)
+	if file := p.file(t, "file1"); file.CurrentStage != 3 {
+		t.Fatalf("after legal approval: stage %d, want 3", file.CurrentStage)
+	}
+
+	// Approving an earlier stage does not count towards the next one
+	p.endorse(t, "tx6", start.Add(4*time.Minute), alice, approve)
+	if file := p.file(t, "file1"); file.Status != "PENDING" {
+		t.Fatalf("final stage with Org1 only: status %s, want PENDING", file.Status)
+	}
+
+	p.endorse(t, "tx7", start.Add(5*time.Minute), carol, approve)
+	file = p.file(t, "file1")
+	if file.Status != "APPROVED" {
+		t.Fatalf("after final sign-off: status %s, want APPROVED", file.Status)
+	}
+	if len(file.CompletedStages) != 3 {
+		t.Fatalf("completed %d stages, want 3", len(file.CompletedStages))
+	}
+	if got := file.CompletedStages[2].Approvals; len(got) != 2 {
+		t.Fatalf("final stage recorded %d approvals, want 2", len(got))
+	}
+
+	transitions := 0
+	for key := range p.stub.State {
+		if strings.HasPrefix(key, "\x00audit\x00file1\x00") && strings.Contains(key, "STAGE_ADVANCE") {
+			transitions++
+		}
+	}
+	if transitions != 3 {
+		t.Fatalf("audited %d stage transitions, want 3", transitions)
+	}
+}
@@ -20,7 +20,10 @@ func (s *SmartContract) RegisterFile(
 	metadata string,
 	previousID string,
 	endorsementConfig string,
-) error {
+	duplicatePolicy string,
+	readers string,
+	versionPolicy string,
+) (string, error) {
 	return handlers.RegisterFile(
 		ctx,
 		id,
@@ -30,6 +33,9 @@ func (s *SmartContract) RegisterFile(
 		metadata,
 		previousID,
 		endorsementConfig,
+		duplicatePolicy,
+		readers,
+		versionPolicy,
 	)
 }
 
@@ -37,14 +43,150 @@ func (s *SmartContract) ApproveFile(ctx contractapi.TransactionContextInterface,
 	return handlers.ApproveFile(ctx, id)
 }
 
+func (s *SmartContract) RejectFile(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	return handlers.RejectFile(ctx, id, reason)
+}
+
+func (s *SmartContract) RevokeApproval(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	return handlers.RevokeApproval(ctx, id, reason)
+}
+
+func (s *SmartContract) ExpirePendingFiles(ctx contractapi.TransactionContextInterface) (string, error) {
+	return handlers.ExpirePendingFiles(ctx)
+}
+
+func (s *SmartContract) ArchiveFile(ctx contractapi.TransactionContextInterface, id string, reason string, wholeChain bool) error {
+	return handlers.ArchiveFile(ctx, id, reason, wholeChain)
+}
+
+func (s *SmartContract) DeleteFile(ctx contractapi.TransactionContextInterface, id string, reason string, wholeChain bool) (string, error) {
+	return handlers.DeleteFile(ctx, id, reason, wholeChain)
+}
+
+func (s *SmartContract) RestoreFile(ctx contractapi.TransactionContextInterface, id string, wholeChain bool) error {
+	return handlers.RestoreFile(ctx, id, wholeChain)
+}
+
+func (s *SmartContract) SetRetention(ctx contractapi.TransactionContextInterface, id string, retainUntil string) error {
+	return handlers.SetRetention(ctx, id, retainUntil)
+}
+
+func (s *SmartContract) PlaceLegalHold(ctx contractapi.TransactionContextInterface, id string, holdID string, reason string) error {
+	return handlers.PlaceLegalHold(ctx, id, holdID, reason)
+}
+
+func (s *SmartContract) ReleaseLegalHold(ctx contractapi.TransactionContextInterface, id string, holdID string) error {
+	return handlers.ReleaseLegalHold(ctx, id, holdID)
+}
+
+func (s *SmartContract) SetLegalHoldOrgs(ctx contractapi.TransactionContextInterface, holders string) error {
+	return handlers.SetLegalHoldOrgs(ctx, holders)
+}
+
+func (s *SmartContract) GetLegalHoldOrgs(ctx contractapi.TransactionContextInterface, mspID string) (string, error) {
+	return handlers.GetLegalHoldOrgs(ctx, mspID)
+}
+
+func (s *SmartContract) IsContentHeld(ctx contractapi.TransactionContextInterface, hash string) (bool, error) {
+	return handlers.IsContentHeld(ctx, hash)
+}
+
+func (s *SmartContract) CreateWorkflow(ctx contractapi.TransactionContextInterface, id string, name string, stagesJSON string) error {
+	return handlers.CreateWorkflow(ctx, id, name, stagesJSON)
+}
+
+func (s *SmartContract) GetWorkflow(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return handlers.GetWorkflow(ctx, id)
+}
+
+func (s *SmartContract) UpdateReaders(ctx contractapi.TransactionContextInterface, id string, readers string) error {
+	return handlers.UpdateReaders(ctx, id, readers)
+}
+
+func (s *SmartContract) UpdateEditors(ctx contractapi.TransactionContextInterface, id string, editors string) error {
+	return handlers.UpdateEditors(ctx, id, editors)
+}
+
+func (s *SmartContract) CanReadFile(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	return handlers.CanReadFile(ctx, id)
+}
+
+func (s *SmartContract) ProposeTransfer(ctx contractapi.TransactionContextInterface, id string, newOwnerMSP string) error {
+	return handlers.ProposeTransfer(ctx, id, newOwnerMSP)
+}
+
+func (s *SmartContract) AcceptTransfer(ctx contractapi.TransactionContextInterface, id string) error {
+	return handlers.AcceptTransfer(ctx, id)
+}
+
+func (s *SmartContract) DeclineTransfer(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	return handlers.DeclineTransfer(ctx, id, reason)
+}
+
+func (s *SmartContract) GetIncomingTransfers(ctx contractapi.TransactionContextInterface) (string, error) {
+	return handlers.GetIncomingTransfers(ctx)
+}
+
+func (s *SmartContract) SetAccessPolicy(ctx contractapi.TransactionContextInterface, rules string) error {
+	return handlers.SetAccessPolicy(ctx, rules)
+}
+
+func (s *SmartContract) GetAccessPolicy(ctx contractapi.TransactionContextInterface, mspID string) (string, error) {
+	return handlers.GetAccessPolicy(ctx, mspID)
+}
+
+func (s *SmartContract) CheckAccess(ctx contractapi.TransactionContextInterface, transaction string) (bool, error) {
+	return handlers.CheckAccess(ctx, transaction)
+}
+
+func (s *SmartContract) RecordAccessDenial(ctx contractapi.TransactionContextInterface, transaction string, targetID string) error {
+	return handlers.RecordAccessDenial(ctx, transaction, targetID)
+}
+
+func (s *SmartContract) RegisterOrganization(ctx contractapi.TransactionContextInterface) error {
+	return handlers.RegisterOrganization(ctx)
+}
+
+func (s *SmartContract) BackfillHashIndex(ctx contractapi.TransactionContextInterface, pageSize int32) (string, error) {
+	return handlers.BackfillHashIndex(ctx, pageSize)
+}
+
+func (s *SmartContract) BackfillAuditIndexes(ctx contractapi.TransactionContextInterface, pageSize int32) (string, error) {
+	return handlers.BackfillAuditIndexes(ctx, pageSize)
+}
+
 func (s *SmartContract) QueryAllFiles(ctx contractapi.TransactionContextInterface) (string, error) {
 	return handlers.QueryAllFiles(ctx)
 }
 
+func (s *SmartContract) QueryFilesPage(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string, filter string) (string, error) {
+	return handlers.QueryFilesPage(ctx, pageSize, bookmark, filter)
+}
+
+func (s *SmartContract) QueryFiles(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int32, bookmark string) (string, error) {
+	return handlers.QueryFiles(ctx, selectorJSON, pageSize, bookmark)
+}
+
 func (s *SmartContract) GetFileByID(ctx contractapi.TransactionContextInterface, id string) (string, error) {
 	return handlers.GetFileByID(ctx, id)
 }
 
+func (s *SmartContract) GetFilePrivateDetails(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return handlers.GetFilePrivateDetails(ctx, id)
+}
+
+func (s *SmartContract) GetFileVersions(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return handlers.GetFileVersions(ctx, id)
+}
+
+func (s *SmartContract) GetLatestVersion(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return handlers.GetLatestVersion(ctx, id)
+}
+
+func (s *SmartContract) GetNextVersions(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return handlers.GetNextVersions(ctx, id)
+}
+
 func (s *SmartContract) GetFileByHash(ctx contractapi.TransactionContextInterface, hash string) (string, error) {
 	return handlers.GetFileByHash(ctx, hash)
 }
@@ -53,8 +195,36 @@ func (s *SmartContract) GetFileAuditLogs(ctx contractapi.TransactionContextInter
 	return handlers.GetFileAuditLogs(ctx, fileID)
 }
 
+func (s *SmartContract) GetFileHistory(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return handlers.GetFileHistory(ctx, id)
+}
+
+func (s *SmartContract) GetFileByIDAsOf(ctx contractapi.TransactionContextInterface, id string, asOf string) (string, error) {
+	return handlers.GetFileByIDAsOf(ctx, id, asOf)
+}
+
+func (s *SmartContract) QueryFilesAsOfPage(ctx contractapi.TransactionContextInterface, asOf string, pageSize int32, bookmark string, filter string) (string, error) {
+	return handlers.QueryFilesAsOfPage(ctx, asOf, pageSize, bookmark, filter)
+}
+
+func (s *SmartContract) QueryAuditLogs(ctx contractapi.TransactionContextInterface, filter string, pageSize int32, bookmark string) (string, error) {
+	return handlers.QueryAuditLogs(ctx, filter, pageSize, bookmark)
+}
+
+func (s *SmartContract) VerifyAuditChain(ctx contractapi.TransactionContextInterface, fileID string) (string, error) {
+	return handlers.VerifyAuditChain(ctx, fileID)
+}
+
 func main() {
-	chaincode, err := contractapi.NewChaincode(new(SmartContract))
+	contract := new(SmartContract)
+
+	// Check each organization's certificate attribute rules before any transaction runs
+	contract.BeforeTransaction = handlers.EnforceAccessPolicy
+
+	// Lets a transaction chain several audit entries for the same file
+	contract.TransactionContextHandler = new(handlers.TransactionContext)
+
+	chaincode, err := contractapi.NewChaincode(contract)
 	if err != nil {
 		fmt.Printf("Error creating chaincode: %s", err.Error())
 		return
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"cli/utils"
+	"dltfm/pkg/models"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -58,20 +59,15 @@ func RegisterFile(filePath, owner string) error {
 		return fmt.Errorf("failed to get file metadata: %v", err)
 	}
 
-	// Create metadata struct and marshal to JSON
-	metadata := struct {
-		Size      int64  `json:"size"`
-		Type      string `json:"type"`
-		CreatedAt string `json:"createdAt"`
-	}{
-		Size:      size,
-		Type:      "text/plain",
-		CreatedAt: time.Now().Format(time.RFC3339),
+	// Describe the file in the current metadata schema
+	metadata := models.FileMetadata{
+		SchemaVersion: models.MetadataSchemaVersion,
+		Size:          size,
+		Type:          "text/plain",
+		CreatedAt:     time.Now().Format(time.RFC3339),
 	}
-
-	metadataBytes, err := json.Marshal(metadata)
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %v", err)
+	if err := metadata.Validate(); err != nil {
+		return fmt.Errorf("invalid metadata: %v", err)
 	}
 
 	// Create chaincode args struct
@@ -91,7 +87,7 @@ func RegisterFile(filePath, owner string) error {
 			name,
 			cleanContent,
 			owner,
-			string(metadataBytes),
+			metadata.String(),
 		},
 	}
 
@@ -106,7 +102,7 @@ func RegisterFile(filePath, owner string) error {
 	fmt.Printf("Name: %s\n", name)
 	fmt.Printf("Content Length: %d\n", len(content))
 	fmt.Printf("Owner: %s\n", owner)
-	fmt.Printf("Metadata: %s\n", string(metadataBytes))
+	fmt.Printf("Metadata: %s\n", metadata.String())
 	fmt.Printf("Final ccArgs: %s\n", string(ccArgsBytes))
 
 	// Build command
@@ -0,0 +1,25 @@
+package models
+
+// AccessPolicy holds one organization's attribute rules for its own users.
+// Rules maps a transaction name, e.g. ApproveFile, to the certificate attributes a caller needs;
+// every listed attribute must match. Transactions without rules are open to the whole organization.
+type AccessPolicy struct {
+	MSPID     string                     `json:"mspId"`
+	Rules     map[string][]AttributeRule `json:"rules"`
+	UpdatedBy string                     `json:"updatedBy"`
+	Timestamp string                     `json:"timestamp"`
+}
+
+// AttributeRule requires a Fabric CA certificate attribute, e.g. role=approver
+type AttributeRule struct {
+	Attribute string `json:"attribute"`
+	Value     string `json:"value"`
+}
+
+// ChannelMember records an organization whose admin has joined it to the file manager.
+// Only members can be named in approval policies, since their peers must endorse the file.
+type ChannelMember struct {
+	MSPID        string `json:"mspId"`
+	RegisteredBy string `json:"registeredBy"`
+	Timestamp    string `json:"timestamp"`
+}
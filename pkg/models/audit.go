@@ -1,10 +1,119 @@
 package models
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditLog is one entry of a file's audit trail. Entries are hash-chained per file:
+// each carries the hash of the entry before it, so editing or removing one breaks the chain.
+// Entries written before chaining was introduced have no sequence or hashes.
 type AuditLog struct {
+	FileID       string `json:"fileId"`
+	Action       string `json:"action"`
+	Timestamp    string `json:"timestamp"`
+	UserID       string `json:"userId"`
+	OrgID        string `json:"orgId"`
+	Details      string `json:"details,omitempty"`
+	TxID         string `json:"txId,omitempty"`
+	Sequence     int    `json:"sequence,omitempty"`     // Position in the file's chain, starting at 1
+	PreviousHash string `json:"previousHash,omitempty"` // Empty for the first entry of a chain
+	Hash         string `json:"hash,omitempty"`
+}
+
+// ComputeHash returns the hex SHA-256 of the entry with its own hash left out
+func (l AuditLog) ComputeHash() string {
+	l.Hash = ""
+	entryJSON, _ := json.Marshal(l) // A struct of strings and ints always marshals
+	sum := sha256.Sum256(entryJSON)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditChainHead records the last entry of a file's audit chain
+type AuditChainHead struct {
+	Sequence int    `json:"sequence"`
+	Hash     string `json:"hash"`
+}
+
+// AuditChainReport is the result of verifying a file's audit chain.
+// When Valid is false, BrokenAt is the sequence of the first entry that does not link up.
+type AuditChainReport struct {
 	FileID    string `json:"fileId"`
-	Action    string `json:"action"`
-	Timestamp string `json:"timestamp"`
-	UserID    string `json:"userId"`
-	OrgID     string `json:"orgId"`
-	Details   string `json:"details,omitempty"`
+	Valid     bool   `json:"valid"`
+	Entries   int    `json:"entries"`   // Chained entries checked
+	Unchained int    `json:"unchained"` // Entries written before chaining, which cannot be verified
+	BrokenAt  int    `json:"brokenAt,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// AuditFilter narrows cross-file audit queries. Empty fields match everything;
+// From and To bound the entry timestamp, both inclusive, in RFC3339.
+type AuditFilter struct {
+	FileID string `json:"fileId,omitempty"`
+	OrgID  string `json:"orgId,omitempty"`
+	UserID string `json:"userId,omitempty"`
+	Action string `json:"action,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// AuditLogPage is one page of audit entries plus the bookmark needed to fetch the next one
+type AuditLogPage struct {
+	Logs                []AuditLog `json:"logs"`
+	Bookmark            string     `json:"bookmark"`
+	FetchedRecordsCount int32      `json:"fetchedRecordsCount"`
+}
+
+// Validate checks that the time bounds of the filter parse
+func (f AuditFilter) Validate() error {
+	for _, bound := range []string{f.From, f.To} {
+		if bound == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, bound); err != nil {
+			return fmt.Errorf("invalid time bound %q, expected RFC3339: %v", bound, err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether an entry satisfies every field set on the filter.
+// Time bounds that do not parse match nothing, call Validate first.
+func (f AuditFilter) Matches(log AuditLog) bool {
+	if f.FileID != "" && log.FileID != f.FileID {
+		return false
+	}
+	if f.OrgID != "" && log.OrgID != f.OrgID {
+		return false
+	}
+	if f.UserID != "" && log.UserID != f.UserID {
+		return false
+	}
+	if f.Action != "" && log.Action != f.Action {
+		return false
+	}
+	if f.From == "" && f.To == "" {
+		return true
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, log.Timestamp)
+	if err != nil {
+		return false
+	}
+	if f.From != "" {
+		from, err := time.Parse(time.RFC3339, f.From)
+		if err != nil || timestamp.Before(from) {
+			return false
+		}
+	}
+	if f.To != "" {
+		to, err := time.Parse(time.RFC3339, f.To)
+		if err != nil || timestamp.After(to) {
+			return false
+		}
+	}
+	return true
 }
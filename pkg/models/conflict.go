@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	versionConflictPrefix = "version conflict"
+	idConflictPrefix      = "id conflict"
+)
+
+// IDConflict is returned when a file is registered under an ID that is already taken
+type IDConflict struct {
+	ID string `json:"id"`
+}
+
+func (e *IDConflict) Error() string {
+	return fmt.Sprintf("%s: file %s already exists", idConflictPrefix, e.ID)
+}
+
+// IsIDConflict reports whether err is, or carries the message of, an IDConflict
+func IsIDConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(*IDConflict); ok {
+		return true
+	}
+	return strings.Contains(err.Error(), idConflictPrefix+": ")
+}
+
+// VersionConflict is returned when a new version is registered on top of a version that
+// already has a successor, e.g. two users editing the same parent. Registering on top of
+// the latest version, or forking explicitly, resolves it.
+type VersionConflict struct {
+	PreviousID string   `json:"previousId"`
+	Successors []string `json:"successors"`
+}
+
+func (e *VersionConflict) Error() string {
+	return fmt.Sprintf("%s: file %s already has newer version %s; register on top of the latest version or fork",
+		versionConflictPrefix, e.PreviousID, strings.Join(e.Successors, ", "))
+}
+
+// IsVersionConflict reports whether err is, or carries the message of, a VersionConflict.
+// Chaincode errors reach gateway clients as plain messages, so the type itself is lost there.
+func IsVersionConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(*VersionConflict); ok {
+		return true
+	}
+	return strings.Contains(err.Error(), versionConflictPrefix+": ")
+}
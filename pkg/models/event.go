@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Chaincode event names. Fabric keeps one event per transaction, so every mutating
+// transaction emits exactly one of these once its changes are made.
+const (
+	// FileEvent payloads
+	EventFileRegistered        = "FileRegistered"
+	EventFileApproved          = "FileApproved" // Status tells whether the approval completed the file or a workflow stage
+	EventFileRejected          = "FileRejected"
+	EventFileApprovalRevoked   = "FileApprovalRevoked"
+	EventFileStatusChanged     = "FileStatusChanged" // Expiry sweeps, listing every expired file in Files
+	EventFileReadersUpdated    = "FileReadersUpdated"
+	EventFileEditorsUpdated    = "FileEditorsUpdated"
+	EventFileTransferProposed  = "FileTransferProposed"
+	EventFileTransferAccepted  = "FileTransferAccepted"
+	EventFileTransferDeclined  = "FileTransferDeclined"
+	EventFileArchived          = "FileArchived"
+	EventFileDeleted           = "FileDeleted"
+	EventFileRestored          = "FileRestored"
+	EventFileRetentionSet      = "FileRetentionSet"
+	EventFileLegalHoldPlaced   = "FileLegalHoldPlaced"
+	EventFileLegalHoldReleased = "FileLegalHoldReleased"
+
+	// LedgerEvent payloads
+	EventWorkflowCreated      = "WorkflowCreated"
+	EventAccessPolicyChanged  = "AccessPolicyChanged"
+	EventLegalHoldOrgsChanged = "LegalHoldOrgsChanged"
+	EventAccessDenied         = "AccessDenied"
+)
+
+// FileEvent is the payload of every File* event, describing the file after the transaction
+type FileEvent struct {
+	FileID    string         `json:"fileId"`
+	Version   int            `json:"version"`
+	Status    string         `json:"status"`
+	ActorMSP  string         `json:"actorMsp"`
+	TxID      string         `json:"txId"`
+	Timestamp string         `json:"timestamp"`       // Transaction time, RFC3339 UTC
+	Files     []FileEventRef `json:"files,omitempty"` // Every file changed, when a transaction changes more than one
+}
+
+// FileEventRef identifies one of several files changed by the same transaction
+type FileEventRef struct {
+	FileID  string `json:"fileId"`
+	Version int    `json:"version"`
+	Status  string `json:"status"`
+}
+
+// LedgerEvent is the payload of events about something other than a file.
+// Subject is the workflow ID, the MSP ID whose access policy changed, or the ID an access denial targeted.
+type LedgerEvent struct {
+	Subject   string `json:"subject"`
+	ActorMSP  string `json:"actorMsp"`
+	TxID      string `json:"txId"`
+	Timestamp string `json:"timestamp"`
+	Details   string `json:"details,omitempty"`
+}
+
+// DecodeEvent unmarshals a chaincode event payload into a *FileEvent or a *LedgerEvent, depending on its name
+func DecodeEvent(name string, payload []byte) (interface{}, error) {
+	switch name {
+	case EventWorkflowCreated, EventAccessPolicyChanged, EventAccessDenied:
+		var event LedgerEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("invalid %s event: %v", name, err)
+		}
+		return &event, nil
+	case EventFileRegistered, EventFileApproved, EventFileRejected, EventFileApprovalRevoked,
+		EventFileStatusChanged, EventFileReadersUpdated, EventFileEditorsUpdated,
+		EventFileTransferProposed, EventFileTransferAccepted, EventFileTransferDeclined,
+		EventFileArchived, EventFileDeleted, EventFileRestored, EventFileRetentionSet,
+		EventFileLegalHoldPlaced, EventFileLegalHoldReleased:
+		var event FileEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("invalid %s event: %v", name, err)
+		}
+		return &event, nil
+	default:
+		return nil, fmt.Errorf("unknown event: %s", name)
+	}
+}
@@ -1,29 +1,140 @@
 package models
 
 import (
-	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 type File struct {
-	ID               string   `json:"id"`
-	Name             string   `json:"name"`
-	Hash             string   `json:"hash"`
-	Timestamp        string   `json:"timestamp"`
-	Owner            string   `json:"owner"`
-	Metadata         string   `json:"metadata"`
-	Version          int      `json:"version"`
-	PreviousID       string   `json:"previousID,omitempty"`
-	IPFSLocation     string   `json:"ipfsLocation"`
-	Status           string   `json:"status"`
-	RequiredOrgs     []string `json:"requiredOrgs"`
-	CurrentApprovals []string `json:"currentApprovals"`
-	EndorsementType  string   `json:"endorsementType"`
+	ID                string               `json:"id"`
+	Name              string               `json:"name"`
+	Hash              string               `json:"hash"`
+	Timestamp         string               `json:"timestamp"`
+	Owner             string               `json:"owner"`
+	OwnerMSP          string               `json:"ownerMsp,omitempty"` // MSP ID of the owning organization
+	OwnerID           string               `json:"ownerId,omitempty"`  // Client identity of the owning user, empty for files registered before transfers
+	PendingTransfer   *OwnershipTransfer   `json:"pendingTransfer,omitempty"`
+	Readers           []ReadRule           `json:"readers,omitempty"` // Empty for files registered before read ACLs, readable by all
+	Editors           []ReadRule           `json:"editors,omitempty"` // Who besides the owner may register new versions on top of the file
+	Metadata          string               `json:"metadata"`
+	MimeType          string               `json:"mimeType,omitempty"`
+	Size              int64                `json:"size,omitempty"`
+	Version           int                  `json:"version"`
+	PreviousID        string               `json:"previousID,omitempty"`
+	DuplicateOf       string               `json:"duplicateOf,omitempty"`
+	IPFSLocation      string               `json:"ipfsLocation"`
+	Status            string               `json:"status"`                     // PENDING, APPROVED, REJECTED or EXPIRED
+	ApprovalDeadline  string               `json:"approvalDeadline,omitempty"` // RFC3339 UTC, approvals are refused from then on
+	RequiredOrgs      []string             `json:"requiredOrgs"`
+	CurrentApprovals  []string             `json:"currentApprovals"`
+	Approvals         []Approval           `json:"approvals,omitempty"`
+	EndorsementType   string               `json:"endorsementType"`
+	EndorsementPolicy string               `json:"endorsementPolicy,omitempty"`
+	RejectionReason   string               `json:"rejectionReason,omitempty"`
+	RejectedBy        string               `json:"rejectedBy,omitempty"`
+	Revocations       []ApprovalRevocation `json:"revocations,omitempty"`
+	WorkflowID        string               `json:"workflowId,omitempty"`
+	CurrentStage      int                  `json:"currentStage,omitempty"` // 1-based stage of the workflow awaiting approval, 0 without a workflow
+	CompletedStages   []StageCompletion    `json:"completedStages,omitempty"`
+	PrivateCollection string               `json:"privateCollection,omitempty"` // Collection holding the metadata of private files
+	PrivateDataHash   string               `json:"privateDataHash,omitempty"`   // SHA-256 of the private details, hex encoded
+	Tombstone         *Tombstone           `json:"tombstone,omitempty"`         // Set while the file is archived or deleted
+	RetainUntil       string               `json:"retainUntil,omitempty"`       // RFC3339 UTC, the file cannot be archived or deleted before then
+	LegalHolds        []LegalHold          `json:"legalHolds,omitempty"`        // Active holds, each blocks archiving, deleting and unpinning
+}
+
+// ReadRule grants read access to the members of an organization, or only to those whose
+// certificate carries the given attribute value, e.g. {"mspId": "Org2MSP", "attribute": "role", "value": "auditor"}
+// Attribute rules only tell users apart when they submit with their own certificates; the server
+// submits with one Admin identity per organization, which carries no attributes.
+type ReadRule struct {
+	MSPID     string `json:"mspId"`
+	Attribute string `json:"attribute,omitempty"`
+	Value     string `json:"value,omitempty"`
+}
+
+// Approval records one user signing off on a file.
+// CurrentApprovals keeps the distinct MSP IDs for clients that only care about organizations.
+type Approval struct {
+	ClientID  string `json:"clientId"`
+	MSPID     string `json:"mspId"`
+	Subject   string `json:"subject,omitempty"` // X.509 subject, empty for non-X.509 identities
+	Timestamp string `json:"timestamp"`
+}
+
+// ApprovalRevocation records a user taking back their approval of a pending file
+type ApprovalRevocation struct {
+	OrgID     string `json:"orgId"`
+	ClientID  string `json:"clientId,omitempty"`
+	Reason    string `json:"reason"`
+	Timestamp string `json:"timestamp"`
+}
+
+// OwnershipTransfer is an offer of a file to another organization, settled when one of its users accepts
+type OwnershipTransfer struct {
+	FileID     string `json:"fileId"`
+	FileName   string `json:"fileName"`
+	FromMSP    string `json:"fromMsp"`
+	FromID     string `json:"fromId"`
+	ToMSP      string `json:"toMsp"`
+	ProposedAt string `json:"proposedAt"`
+}
+
+// Tombstone marks a file as retired. The record and its history stay on the ledger,
+// it is only hidden from default queries until restored.
+type Tombstone struct {
+	State     string `json:"state"` // ARCHIVED or DELETED
+	Reason    string `json:"reason"`
+	MSPID     string `json:"mspId"`
+	ClientID  string `json:"clientId"`
+	Timestamp string `json:"timestamp"`
+}
+
+// LegalHold stops a file from being archived or deleted, and its content from being unpinned,
+// until the organization that placed it releases it
+type LegalHold struct {
+	ID       string `json:"id"` // Matter or case reference, unique per file
+	Reason   string `json:"reason"`
+	MSPID    string `json:"mspId"`
+	ClientID string `json:"clientId"`
+	PlacedAt string `json:"placedAt"`
+}
+
+// LegalHoldOrgs lists the organizations an owning organization lets place legal holds on its files
+type LegalHoldOrgs struct {
+	MSPID     string   `json:"mspId"`
+	Holders   []string `json:"holders"`
+	UpdatedBy string   `json:"updatedBy"`
+	Timestamp string   `json:"timestamp"`
+}
+
+// DeletionReport lists the files one DeleteFile transaction deleted, and the content
+// no live file references any more, which may be unpinned from IPFS
+type DeletionReport struct {
+	Deleted      []string `json:"deleted"`
+	Unreferenced []string `json:"unreferenced"`
+}
+
+// FilePrivateDetails holds the confidential part of a private file, kept in a private data collection.
+// The public record of a private file leaves Owner empty; OwnerMSP and OwnerID stay public for access checks.
+type FilePrivateDetails struct {
+	ID       string `json:"id"`
+	Owner    string `json:"owner"`
+	Metadata string `json:"metadata"`
+}
+
+// ExpiryReport lists the files moved to EXPIRED by one ExpirePendingFiles transaction
+type ExpiryReport struct {
+	Expired []string `json:"expired"`
+	Count   int      `json:"count"`
 }
 
 func (f *File) FormatCLI() string {
-	var metadataMap map[string]interface{}
-	json.Unmarshal([]byte(f.Metadata), &metadataMap)
+	metadata := "  Private:     kept in collection " + f.PrivateCollection
+	if f.PrivateCollection == "" {
+		metadata = formatMetadataCLI(f.Metadata)
+	}
 
 	return fmt.Sprintf(`
 File Details:
@@ -34,11 +145,9 @@ File Details:
   Version:     %d
   Hash:        %s
   Status:      %s
-Metadata:
-  Size:        %v bytes
-  Type:        %v
-  Created:     %v
   Previous ID: %s
+Metadata:
+%s
 Endorsement:
   Type:        %s
   Required:    %v
@@ -50,15 +159,44 @@ Endorsement:
 		f.Version,
 		f.Hash,
 		f.Status,
-		metadataMap["size"],
-		metadataMap["type"],
-		metadataMap["createdAt"],
 		f.PreviousID,
+		metadata,
 		f.EndorsementType,
 		f.RequiredOrgs,
 		f.CurrentApprovals)
 }
 
+// Formats the metadata section of FormatCLI, reporting metadata that does not decode
+func formatMetadataCLI(raw string) string {
+	metadata, err := ParseMetadata(raw)
+	if err != nil {
+		return fmt.Sprintf("  Invalid:     %v", err)
+	}
+
+	lines := []string{
+		fmt.Sprintf("  Size:        %d bytes", metadata.Size),
+		fmt.Sprintf("  Type:        %s", metadata.Type),
+		fmt.Sprintf("  Created:     %s", metadata.CreatedAt),
+	}
+	if metadata.Encoding != "" {
+		lines = append(lines, fmt.Sprintf("  Encoding:    %s", metadata.Encoding))
+	}
+	if metadata.Description != "" {
+		lines = append(lines, fmt.Sprintf("  Description: %s", metadata.Description))
+	}
+
+	keys := make([]string, 0, len(metadata.Custom))
+	for key := range metadata.Custom {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("  %s: %s", key, metadata.Custom[key]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 func FormatFileList(files []File) string {
 	if len(files) == 0 {
 		return "No files found in the ledger"
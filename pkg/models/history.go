@@ -0,0 +1,80 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// FileHistoryEntry is one committed value of a file's ledger key, as recorded by the peer
+// rather than by the chaincode's own audit log
+type FileHistoryEntry struct {
+	TxID      string        `json:"txId"`
+	Timestamp string        `json:"timestamp"` // Transaction time, RFC3339 with fractional seconds
+	IsDelete  bool          `json:"isDelete"`
+	File      *File         `json:"file,omitempty"`    // Nil when the key was deleted
+	Changes   []FieldChange `json:"changes,omitempty"` // Fields that differ from the previous entry
+}
+
+// FieldChange is the value of one top-level JSON field before and after a change.
+// Before is absent when the field was added, After when it was removed.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// DiffFields compares two JSON objects field by field, returning the changes sorted by field name.
+// A nil or empty value stands for a missing object, so every field of the other one is a change.
+func DiffFields(before []byte, after []byte) ([]FieldChange, error) {
+	beforeFields, err := objectFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := objectFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for name := range beforeFields {
+		names[name] = true
+	}
+	for name := range afterFields {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	changes := []FieldChange{}
+	for _, name := range sorted {
+		if !bytes.Equal(beforeFields[name], afterFields[name]) {
+			changes = append(changes, FieldChange{Field: name, Before: beforeFields[name], After: afterFields[name]})
+		}
+	}
+	return changes, nil
+}
+
+// Splits a JSON object into its compacted top-level fields
+func objectFields(value []byte) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if len(value) == 0 {
+		return fields, nil
+	}
+
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON object: %v", err)
+	}
+	for name, raw := range fields {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, raw); err != nil {
+			return nil, fmt.Errorf("invalid value of field %s: %v", name, err)
+		}
+		fields[name] = compacted.Bytes()
+	}
+	return fields, nil
+}
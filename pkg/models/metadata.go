@@ -0,0 +1,181 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Current version of the metadata schema. Metadata without a schemaVersion predates it.
+const MetadataSchemaVersion = 1
+
+// Limits on the free-form parts of the metadata, which every peer stores
+const (
+	maxDescriptionLength = 1024
+	maxCustomFields      = 32
+	maxCustomKeyLength   = 64
+	maxCustomValueLength = 1024
+)
+
+// FileMetadata describes a file's content. The ledger keeps it as a JSON string in
+// File.Metadata; use ParseMetadata to read it and NormalizeMetadata before storing it.
+type FileMetadata struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	Size          int64             `json:"size"`
+	Type          string            `json:"type,omitempty"`      // MIME type, e.g. application/pdf
+	Encoding      string            `json:"encoding,omitempty"`  // How the content was encoded for upload, e.g. base64
+	CreatedAt     string            `json:"createdAt,omitempty"` // When the file was created, RFC3339
+	Description   string            `json:"description,omitempty"`
+	Custom        map[string]string `json:"custom,omitempty"`
+}
+
+// ParseMetadata decodes metadata as stored on the ledger. Metadata written before the
+// schema decodes too: its extra fields and fields of an unexpected type become custom values,
+// and plain text, or JSON that is not an object, becomes the description.
+func ParseMetadata(raw string) (*FileMetadata, error) {
+	if strings.TrimSpace(raw) == "" {
+		return &FileMetadata{}, nil
+	}
+	if !json.Valid([]byte(raw)) || !strings.HasPrefix(strings.TrimSpace(raw), "{") {
+		return &FileMetadata{Description: raw}, nil
+	}
+	return decodeMetadata(raw, false)
+}
+
+// NormalizeMetadata decodes and validates metadata submitted with a new file, returning it
+// at the current schema version. Unlike ParseMetadata it rejects anything it cannot type.
+func NormalizeMetadata(raw string) (*FileMetadata, error) {
+	metadata := &FileMetadata{}
+	if strings.TrimSpace(raw) != "" {
+		var err error
+		if metadata, err = decodeMetadata(raw, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := metadata.Validate(); err != nil {
+		return nil, err
+	}
+
+	metadata.SchemaVersion = MetadataSchemaVersion
+	return metadata, nil
+}
+
+// Validate checks every field against the schema
+func (m *FileMetadata) Validate() error {
+	if m.Size < 0 {
+		return fmt.Errorf("size must not be negative, got %d", m.Size)
+	}
+	if m.Type != "" {
+		mediaType, _, err := mime.ParseMediaType(m.Type)
+		if err != nil || !strings.Contains(mediaType, "/") {
+			return fmt.Errorf("invalid MIME type %q", m.Type)
+		}
+	}
+	if m.Encoding != "" && strings.IndexFunc(m.Encoding, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	}) >= 0 {
+		return fmt.Errorf("invalid encoding %q", m.Encoding)
+	}
+	if m.CreatedAt != "" {
+		if _, err := time.Parse(time.RFC3339, m.CreatedAt); err != nil {
+			return fmt.Errorf("invalid createdAt %q, expected RFC3339: %v", m.CreatedAt, err)
+		}
+	}
+	if len(m.Description) > maxDescriptionLength {
+		return fmt.Errorf("description is longer than %d characters", maxDescriptionLength)
+	}
+	if len(m.Custom) > maxCustomFields {
+		return fmt.Errorf("at most %d custom fields are allowed, got %d", maxCustomFields, len(m.Custom))
+	}
+	for key, value := range m.Custom {
+		if key == "" || len(key) > maxCustomKeyLength {
+			return fmt.Errorf("custom field names must be 1 to %d characters, got %q", maxCustomKeyLength, key)
+		}
+		if len(value) > maxCustomValueLength {
+			return fmt.Errorf("custom field %s is longer than %d characters", key, maxCustomValueLength)
+		}
+	}
+	return nil
+}
+
+// String returns the metadata as the JSON stored in File.Metadata
+func (m *FileMetadata) String() string {
+	metadataJSON, _ := json.Marshal(m) // Strings, ints and a string map always marshal
+	return string(metadataJSON)
+}
+
+// Decodes a JSON object in either the current schema or the shape that preceded it.
+// Strict decoding refuses unknown fields and values it would have to convert.
+func decodeMetadata(raw string, strict bool) (*FileMetadata, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return nil, fmt.Errorf("metadata must be a JSON object: %v", err)
+	}
+
+	var metadata FileMetadata
+	if version, ok := fields["schemaVersion"]; ok {
+		if err := json.Unmarshal(version, &metadata.SchemaVersion); err != nil {
+			return nil, fmt.Errorf("invalid metadata schema version %s", version)
+		}
+		if metadata.SchemaVersion != MetadataSchemaVersion {
+			return nil, fmt.Errorf("unsupported metadata schema version %d", metadata.SchemaVersion)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+		if err := decoder.Decode(&metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata: %v", err)
+		}
+		return &metadata, nil
+	}
+
+	// Unversioned metadata: the typed fields keep their names, anything else is a custom value
+	targets := map[string]interface{}{
+		"size":        &metadata.Size,
+		"type":        &metadata.Type,
+		"encoding":    &metadata.Encoding,
+		"createdAt":   &metadata.CreatedAt,
+		"description": &metadata.Description,
+		"custom":      &metadata.Custom,
+	}
+	for name, target := range targets {
+		value, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			if strict {
+				return nil, fmt.Errorf("invalid metadata field %s: %v", name, err)
+			}
+
+			// Older clients used some of these names for values of another type, keep those as
+			// custom values instead. A custom object may have been decoded in part, drop that.
+			if name == "custom" {
+				metadata.Custom = nil
+			}
+			continue
+		}
+		delete(fields, name)
+	}
+	for name, value := range fields {
+
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			if strict {
+				return nil, fmt.Errorf("metadata field %s must be a string to be kept as a custom value", name)
+			}
+			text = string(value)
+		}
+		if metadata.Custom == nil {
+			metadata.Custom = map[string]string{}
+		}
+		metadata.Custom[name] = text
+	}
+
+	return &metadata, nil
+}
@@ -0,0 +1,380 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Policy is a parsed approval policy, modelled on Fabric's signature policy syntax:
+//
+//	AND('Org1MSP', 'Org2MSP')
+//	OR('Org1MSP', AND('Org2MSP', 'Org3MSP'))
+//	OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')
+//	AND(Signers(2, 'Org1MSP'), 'Org2MSP')
+//
+// AND and OR are stored as OutOf with N equal to all or one of the rules.
+// Signers(n, 'Org1MSP') asks for n distinct signers from one organization and is shorthand
+// for AND with the principal repeated n times, which is how Fabric expresses it. Signers are
+// told apart by client certificate, so the server's shared organization identity counts once.
+// A leaf names a single MSP principal, optionally written as 'Org1MSP.member'.
+type Policy struct {
+	Principal string    // MSP ID, set only on leaves
+	N         int       // Number of rules that must be satisfied, set only on OutOf nodes
+	Rules     []*Policy // Sub-policies, set only on OutOf nodes
+}
+
+// Parse compiles a policy expression and checks it is well formed
+func Parse(expression string) (*Policy, error) {
+	p := &parser{input: expression}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("policy expression is empty")
+	}
+
+	policy, err := p.parseRule()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q after end of policy", p.tokens[p.pos].text)
+	}
+
+	return policy, nil
+}
+
+// AllOf builds the policy requiring every listed organization
+func AllOf(orgs []string) *Policy {
+	return OutOf(len(orgs), orgs)
+}
+
+// AnyOf builds the policy requiring any one of the listed organizations
+func AnyOf(orgs []string) *Policy {
+	return OutOf(1, orgs)
+}
+
+// OutOf builds the policy requiring n of the listed organizations
+func OutOf(n int, orgs []string) *Policy {
+	rules := make([]*Policy, len(orgs))
+	for i, org := range orgs {
+		rules[i] = &Policy{Principal: org}
+	}
+	return &Policy{N: n, Rules: rules}
+}
+
+// Evaluate reports whether the approvals satisfy the policy.
+// Approvals are MSP IDs, one per signer. As in Fabric, each approval is consumed by the
+// first principal it satisfies, so AND('Org1MSP', 'Org1MSP') needs two Org1MSP approvals.
+func (p *Policy) Evaluate(approvals []string) bool {
+	used := make([]bool, len(approvals))
+	return p.evaluate(approvals, used)
+}
+
+func (p *Policy) evaluate(approvals []string, used []bool) bool {
+	if p.Rules == nil {
+		for i, approval := range approvals {
+			if !used[i] && approval == p.Principal {
+				used[i] = true
+				return true
+			}
+		}
+		return false
+	}
+
+	satisfied := 0
+	for _, rule := range p.Rules {
+		// Let the rule consume approvals on a scratch copy and keep them only if it succeeds
+		scratch := make([]bool, len(used))
+		copy(scratch, used)
+		if rule.evaluate(approvals, scratch) {
+			copy(used, scratch)
+			satisfied++
+		}
+	}
+	return satisfied >= p.N
+}
+
+// SatisfiableOncePerOrg reports whether one signer from each organization the policy names is
+// enough to satisfy it, which is all a client that approves for an organization with a single
+// identity can offer
+func (p *Policy) SatisfiableOncePerOrg() bool {
+	return p.Evaluate(p.Orgs())
+}
+
+// Orgs lists every MSP ID named in the policy, sorted and without duplicates
+func (p *Policy) Orgs() []string {
+	seen := map[string]bool{}
+	p.collectOrgs(seen)
+
+	orgs := make([]string, 0, len(seen))
+	for org := range seen {
+		orgs = append(orgs, org)
+	}
+	sort.Strings(orgs)
+	return orgs
+}
+
+func (p *Policy) collectOrgs(seen map[string]bool) {
+	if p.Rules == nil {
+		seen[p.Principal] = true
+		return
+	}
+	for _, rule := range p.Rules {
+		rule.collectOrgs(seen)
+	}
+}
+
+// Mentions reports whether the MSP ID appears anywhere in the policy
+func (p *Policy) Mentions(mspID string) bool {
+	if p.Rules == nil {
+		return p.Principal == mspID
+	}
+	for _, rule := range p.Rules {
+		if rule.Mentions(mspID) {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the policy in canonical form, using AND and OR where they apply
+func (p *Policy) String() string {
+	if p.Rules == nil {
+		return "'" + p.Principal + "'"
+	}
+
+	rules := make([]string, len(p.Rules))
+	for i, rule := range p.Rules {
+		rules[i] = rule.String()
+	}
+
+	switch {
+	case p.N == len(p.Rules):
+		return "AND(" + strings.Join(rules, ", ") + ")"
+	case p.N == 1:
+		return "OR(" + strings.Join(rules, ", ") + ")"
+	default:
+		return fmt.Sprintf("OutOf(%d, %s)", p.N, strings.Join(rules, ", "))
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) tokenize() error {
+	runes := []rune(p.input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			p.tokens = append(p.tokens, token{tokenLParen, "("})
+			i++
+		case r == ')':
+			p.tokens = append(p.tokens, token{tokenRParen, ")"})
+			i++
+		case r == ',':
+			p.tokens = append(p.tokens, token{tokenComma, ","})
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return fmt.Errorf("unterminated principal starting at position %d", i)
+			}
+			p.tokens = append(p.tokens, token{tokenString, string(runes[i+1 : end])})
+			i = end + 1
+		case unicode.IsDigit(r):
+			end := i
+			for end < len(runes) && unicode.IsDigit(runes[end]) {
+				end++
+			}
+			p.tokens = append(p.tokens, token{tokenNumber, string(runes[i:end])})
+			i = end
+		case unicode.IsLetter(r):
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) {
+				end++
+			}
+			p.tokens = append(p.tokens, token{tokenIdent, string(runes[i:end])})
+			i = end
+		default:
+			return fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+	return nil
+}
+
+func (p *parser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, fmt.Errorf("unexpected end of policy")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.kind != kind {
+		return fmt.Errorf("expected %q, found %q", text, t.text)
+	}
+	return nil
+}
+
+// rule := principal | AND(rules) | OR(rules) | OutOf(n, rules) | Signers(n, principal)
+func (p *parser) parseRule() (*Policy, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.kind {
+	case tokenString:
+		return parsePrincipal(t.text)
+	case tokenIdent:
+		// handled below
+	default:
+		return nil, fmt.Errorf("expected a principal or AND, OR, OutOf, found %q", t.text)
+	}
+
+	operator := strings.ToUpper(t.text)
+	if operator != "AND" && operator != "OR" && operator != "OUTOF" && operator != "SIGNERS" {
+		return nil, fmt.Errorf("unknown policy operator %q", t.text)
+	}
+	if err := p.expect(tokenLParen, "("); err != nil {
+		return nil, err
+	}
+
+	n := 0
+	if operator == "OUTOF" || operator == "SIGNERS" {
+		if n, err = p.parseCount(t.text); err != nil {
+			return nil, err
+		}
+	}
+
+	if operator == "SIGNERS" {
+		return p.parseSigners(n)
+	}
+
+	var rules []*Policy
+	for {
+		rule, err := p.parseRule()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == tokenRParen {
+			break
+		}
+		if t.kind != tokenComma {
+			return nil, fmt.Errorf("expected \",\" or \")\", found %q", t.text)
+		}
+	}
+
+	switch operator {
+	case "AND":
+		n = len(rules)
+	case "OR":
+		n = 1
+	}
+	if n < 1 || n > len(rules) {
+		return nil, fmt.Errorf("OutOf count %d must be between 1 and %d", n, len(rules))
+	}
+
+	return &Policy{N: n, Rules: rules}, nil
+}
+
+// count := number ","
+func (p *parser) parseCount(operator string) (int, error) {
+	countToken, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	if countToken.kind != tokenNumber {
+		return 0, fmt.Errorf("%s expects a count, found %q", operator, countToken.text)
+	}
+	n, err := strconv.Atoi(countToken.text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s count %q: %v", operator, countToken.text, err)
+	}
+	if err := p.expect(tokenComma, ","); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Parses the principal of Signers(n, principal) and expands it to n copies
+func (p *parser) parseSigners(n int) (*Policy, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind != tokenString {
+		return nil, fmt.Errorf("Signers expects a single principal, found %q", t.text)
+	}
+	principal, err := parsePrincipal(t.text)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokenRParen, ")"); err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("Signers count %d must be at least 1", n)
+	}
+
+	return OutOf(n, slices.Repeat([]string{principal.Principal}, n)), nil
+}
+
+// Accepts 'Org1MSP' or 'Org1MSP.member'. Approvals are tracked per organization,
+// so narrower Fabric roles such as admin or peer cannot be honoured and are refused.
+func parsePrincipal(text string) (*Policy, error) {
+	mspID := text
+	if dot := strings.LastIndex(text, "."); dot >= 0 {
+		role := text[dot+1:]
+		if role != "member" {
+			return nil, fmt.Errorf("unsupported role %q in principal %q, only member is supported", role, text)
+		}
+		mspID = text[:dot]
+	}
+	if mspID == "" {
+		return nil, fmt.Errorf("principal must name an MSP")
+	}
+	return &Policy{Principal: mspID}, nil
+}
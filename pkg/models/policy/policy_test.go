@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"testing"
+)
+
+func TestParseAndEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		approvals  []string
+		want       bool
+	}{
+		{"'Org1MSP'", []string{"Org1MSP"}, true},
+		{"'Org1MSP.member'", []string{"Org1MSP"}, true},
+		{"AND('Org1MSP', 'Org2MSP')", []string{"Org1MSP"}, false},
+		{"AND('Org1MSP', 'Org2MSP')", []string{"Org2MSP", "Org1MSP"}, true},
+		{"OR('Org1MSP', 'Org2MSP')", []string{"Org2MSP"}, true},
+		{"OR('Org1MSP', 'Org2MSP')", []string{"Org3MSP"}, false},
+		{"OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')", []string{"Org3MSP"}, false},
+		{"OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')", []string{"Org1MSP", "Org3MSP"}, true},
+		{"OR('Org1MSP', AND('Org2MSP', 'Org3MSP'))", []string{"Org2MSP"}, false},
+		{"OR('Org1MSP', AND('Org2MSP', 'Org3MSP'))", []string{"Org2MSP", "Org3MSP"}, true},
+		{"outof(1, \"Org1MSP\")", []string{"Org1MSP"}, true},
+		// Each approval satisfies one principal only
+		{"AND('Org1MSP', 'Org1MSP')", []string{"Org1MSP"}, false},
+		{"AND('Org1MSP', 'Org1MSP')", []string{"Org1MSP", "Org1MSP"}, true},
+		// Signers asks for distinct users of one organization
+		{"AND(Signers(2, 'Org1MSP'), 'Org2MSP')", []string{"Org1MSP", "Org2MSP"}, false},
+		{"AND(Signers(2, 'Org1MSP'), 'Org2MSP')", []string{"Org1MSP", "Org2MSP", "Org1MSP"}, true},
+		{"OR(Signers(3, 'Org1MSP'), 'Org2MSP')", []string{"Org2MSP"}, true},
+	}
+
+	for _, tt := range tests {
+		p, err := Parse(tt.expression)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.expression, err)
+		}
+		if got := p.Evaluate(tt.approvals); got != tt.want {
+			t.Errorf("%s with approvals %v = %v, want %v", tt.expression, tt.approvals, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsMalformedPolicies(t *testing.T) {
+	expressions := []string{
+		"",
+		"AND()",
+		"AND('Org1MSP'",
+		"XOR('Org1MSP', 'Org2MSP')",
+		"OutOf(3, 'Org1MSP', 'Org2MSP')",
+		"OutOf(0, 'Org1MSP')",
+		"OutOf('Org1MSP')",
+		"'Org1MSP.admin'",
+		"'Org1MSP' 'Org2MSP'",
+		"'Org1MSP",
+		"Signers(0, 'Org1MSP')",
+		"Signers(2, 'Org1MSP', 'Org2MSP')",
+		"Signers(2, AND('Org1MSP', 'Org2MSP'))",
+	}
+
+	for _, expression := range expressions {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expression)
+		}
+	}
+}
+
+func TestStringRoundTrips(t *testing.T) {
+	tests := map[string]string{
+		"and('Org1MSP','Org2MSP')":                        "AND('Org1MSP', 'Org2MSP')",
+		"OutOf(1, 'Org1MSP', 'Org2MSP')":                  "OR('Org1MSP', 'Org2MSP')",
+		"OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')":       "OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')",
+		"OR('Org1MSP.member', AND('Org2MSP', 'Org3MSP'))": "OR('Org1MSP', AND('Org2MSP', 'Org3MSP'))",
+		"Signers(2, 'Org1MSP')":                           "AND('Org1MSP', 'Org1MSP')",
+	}
+
+	for expression, want := range tests {
+		p, err := Parse(expression)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", expression, err)
+		}
+		if got := p.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", expression, got, want)
+		}
+		if _, err := Parse(p.String()); err != nil {
+			t.Errorf("canonical form %q does not parse: %v", p.String(), err)
+		}
+	}
+}
+
+func TestOrgs(t *testing.T) {
+	p, err := Parse("OR('Org2MSP', AND('Org1MSP', 'Org2MSP', 'Org3MSP'))")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orgs := p.Orgs()
+	want := []string{"Org1MSP", "Org2MSP", "Org3MSP"}
+	if len(orgs) != len(want) {
+		t.Fatalf("Orgs() = %v, want %v", orgs, want)
+	}
+	for i := range want {
+		if orgs[i] != want[i] {
+			t.Fatalf("Orgs() = %v, want %v", orgs, want)
+		}
+	}
+
+	if !p.Mentions("Org3MSP") || p.Mentions("Org4MSP") {
+		t.Error("Mentions does not match the policy principals")
+	}
+}
+
+func TestSatisfiableOncePerOrg(t *testing.T) {
+	for expression, want := range map[string]bool{
+		"AND('Org1MSP', 'Org2MSP')":                 true,
+		"OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')": true,
+		"OR(Signers(2, 'Org1MSP'), 'Org2MSP')":      true,
+		"Signers(1, 'Org1MSP')":                     true,
+		"AND(Signers(2, 'Org1MSP'), 'Org2MSP')":     false,
+		"AND('Org1MSP', 'Org1MSP')":                 false,
+	} {
+		p, err := Parse(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.SatisfiableOncePerOrg(); got != want {
+			t.Errorf("SatisfiableOncePerOrg(%s) = %v, want %v", expression, got, want)
+		}
+	}
+}
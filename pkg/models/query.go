@@ -0,0 +1,60 @@
+package models
+
+// FileFilter narrows paginated file queries. Empty fields match everything,
+// except Lifecycle: archived and deleted files are only returned when asked for.
+type FileFilter struct {
+	Owner           string `json:"owner,omitempty"`
+	Status          string `json:"status,omitempty"`
+	EndorsementType string `json:"endorsementType,omitempty"`
+	MimeType        string `json:"mimeType,omitempty"`
+	Lifecycle       string `json:"lifecycle,omitempty"` // Empty for live files only, ARCHIVED, DELETED or ALL
+}
+
+// FilePage is one page of files plus the bookmark needed to fetch the next one
+type FilePage struct {
+	Files               []File `json:"files"`
+	Bookmark            string `json:"bookmark"`
+	FetchedRecordsCount int32  `json:"fetchedRecordsCount"`
+}
+
+// Matches reports whether a file satisfies every field set on the filter
+func (f FileFilter) Matches(file File) bool {
+	if f.Lifecycle != "ALL" {
+		state := ""
+		if file.Tombstone != nil {
+			state = file.Tombstone.State
+		}
+		if state != f.Lifecycle {
+			return false
+		}
+	}
+	if f.Owner != "" && file.Owner != f.Owner {
+		return false
+	}
+	if f.Status != "" && file.Status != f.Status {
+		return false
+	}
+	if f.EndorsementType != "" && file.EndorsementType != f.EndorsementType {
+		return false
+	}
+	if f.MimeType != "" && file.MimeType != f.MimeType {
+		// Files registered before MIME types were indexed only carry it inside the metadata
+		if file.MimeType != "" {
+			return false
+		}
+		metadata, err := ParseMetadata(file.Metadata)
+		if err != nil || metadata.Type != f.MimeType {
+			return false
+		}
+	}
+	return true
+}
+
+// BackfillProgress records how far an index backfill has got through the records written
+// before the index existed
+type BackfillProgress struct {
+	Index    string `json:"index"`
+	Bookmark string `json:"bookmark,omitempty"` // Where the next run resumes
+	Indexed  int    `json:"indexed"`            // Records added to the index so far
+	Complete bool   `json:"complete"`
+}
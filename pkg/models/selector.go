@@ -0,0 +1,151 @@
+package models
+
+import (
+	"fmt"
+)
+
+// QueryableFileFields lists the File fields that rich query selectors may reference
+var QueryableFileFields = map[string]bool{
+	"id":               true,
+	"name":             true,
+	"hash":             true,
+	"timestamp":        true,
+	"owner":            true,
+	"version":          true,
+	"previousID":       true,
+	"status":           true,
+	"requiredOrgs":     true,
+	"currentApprovals": true,
+	"endorsementType":  true,
+	"mimeType":         true,
+	"size":             true,
+	"tombstone.state":  true,
+}
+
+// Mango operators that combine whole selectors
+var selectorCombinators = map[string]bool{
+	"$and": true,
+	"$or":  true,
+	"$nor": true,
+	"$not": true,
+}
+
+// Mango operators that test a single field value
+var selectorConditions = map[string]bool{
+	"$lt":        true,
+	"$lte":       true,
+	"$eq":        true,
+	"$ne":        true,
+	"$gte":       true,
+	"$gt":        true,
+	"$exists":    true,
+	"$type":      true,
+	"$in":        true,
+	"$nin":       true,
+	"$size":      true,
+	"$mod":       true,
+	"$regex":     true,
+	"$all":       true,
+	"$elemMatch": true,
+	"$allMatch":  true,
+}
+
+// ValidateSelector checks that a CouchDB Mango selector only references queryable File fields
+// and known operators, so callers cannot probe audit records or other non-file state.
+func ValidateSelector(selector map[string]interface{}) error {
+	if len(selector) == 0 {
+		return fmt.Errorf("selector must not be empty")
+	}
+
+	for key, value := range selector {
+		if selectorCombinators[key] {
+			if err := validateCombinator(key, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !QueryableFileFields[key] {
+			return fmt.Errorf("field %q is not queryable", key)
+		}
+		if err := validateCondition(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SelectorReferences reports whether a selector tests a field anywhere, including inside
+// $and, $or, $nor and $not
+func SelectorReferences(selector map[string]interface{}, field string) bool {
+	for key, value := range selector {
+		if key == field {
+			return true
+		}
+		if !selectorCombinators[key] {
+			continue
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok && SelectorReferences(nested, field) {
+			return true
+		}
+		items, _ := value.([]interface{})
+		for _, item := range items {
+			if nested, ok := item.(map[string]interface{}); ok && SelectorReferences(nested, field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validates the operand of $and, $or, $nor (selector arrays) and $not (a single selector)
+func validateCombinator(operator string, value interface{}) error {
+	if operator == "$not" {
+		selector, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s expects a selector object", operator)
+		}
+		return ValidateSelector(selector)
+	}
+
+	selectors, ok := value.([]interface{})
+	if !ok || len(selectors) == 0 {
+		return fmt.Errorf("%s expects a non-empty array of selectors", operator)
+	}
+	for _, item := range selectors {
+		selector, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s expects a non-empty array of selectors", operator)
+		}
+		if err := ValidateSelector(selector); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validates the value given for a field, which is either a literal or an object of condition operators
+func validateCondition(field string, value interface{}) error {
+	conditions, ok := value.(map[string]interface{})
+	if !ok {
+		return nil // Literal values are an implicit $eq
+	}
+
+	for operator, operand := range conditions {
+		if !selectorConditions[operator] {
+			return fmt.Errorf("operator %q is not allowed on field %q", operator, field)
+		}
+
+		// Array element matchers take operator-only conditions on the element itself
+		if operator == "$elemMatch" || operator == "$allMatch" {
+			if err := validateCondition(field, operand); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+// Workflow is an ordered list of approval stages, stored on chain and shared by many files
+type Workflow struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Stages    []WorkflowStage `json:"stages"`
+	CreatedBy string          `json:"createdBy"` // MSP ID of the defining organization
+	Timestamp string          `json:"timestamp"`
+}
+
+// WorkflowStage is one step of a workflow, e.g. technical review or legal
+type WorkflowStage struct {
+	Name   string `json:"name"`
+	Policy string `json:"policy"` // Policy expression, e.g. AND('Org1MSP', 'Org2MSP')
+}
+
+// StageCompletion records a workflow stage whose policy was met
+type StageCompletion struct {
+	Stage       int        `json:"stage"`
+	Name        string     `json:"name"`
+	Approvals   []Approval `json:"approvals"`
+	CompletedAt string     `json:"completedAt"`
+}
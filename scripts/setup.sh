@@ -255,7 +255,8 @@ deploy_chaincode() {
             --sequence $SEQUENCE \
             --tls \
             --cafile $FABRIC_SAMPLES_DIR/test-network/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem \
-            --signature-policy "OR('Org1MSP.member','Org2MSP.member')"
+            --signature-policy "OR('Org1MSP.member','Org2MSP.member')" \
+            --collections-config "$SCRIPT_DIR/../chaincode/collections_config.json"
 
         check_status "Chaincode approval for Org$org" || return 1
     done
@@ -281,7 +282,8 @@ deploy_chaincode() {
         --tlsRootCertFiles $FABRIC_SAMPLES_DIR/test-network/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt \
         --peerAddresses localhost:9051 \
         --tlsRootCertFiles $FABRIC_SAMPLES_DIR/test-network/organizations/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt \
-        --signature-policy "OR('Org1MSP.member','Org2MSP.member')"
+        --signature-policy "OR('Org1MSP.member','Org2MSP.member')" \
+        --collections-config "$SCRIPT_DIR/../chaincode/collections_config.json"
 
     check_status "Chaincode commitment" || return 1
 
@@ -290,9 +292,66 @@ deploy_chaincode() {
     peer lifecycle chaincode querycommitted --channelID mychannel --name $CHAINCODE_NAME
     check_status "Deployment verification" || return 1
 
+    register_organizations || return 1
+    backfill_indexes || return 1
+
     log "success" "Chaincode deployment completed successfully!"
     return 0
 }
+
+# Function to register each organization as a channel member, so approval policies can name it.
+# Registration must be signed by an admin of the organization itself.
+register_organizations() {
+    for org in 1 2; do
+        set_org_context "Org$org" $((7051 + (org-1)*2000))
+        show_progress "Registering Org$org as a channel member..."
+        peer chaincode invoke \
+            -o localhost:7050 \
+            --ordererTLSHostnameOverride orderer.example.com \
+            --tls \
+            --cafile $FABRIC_SAMPLES_DIR/test-network/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem \
+            -C mychannel \
+            -n $CHAINCODE_NAME \
+            --peerAddresses localhost:7051 \
+            --tlsRootCertFiles $FABRIC_SAMPLES_DIR/test-network/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt \
+            --peerAddresses localhost:9051 \
+            --tlsRootCertFiles $FABRIC_SAMPLES_DIR/test-network/organizations/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt \
+            --waitForEvent \
+            -c '{"function":"RegisterOrganization","Args":[]}'
+        check_status "Registering Org$org" || return 1
+    done
+    return 0
+}
+
+# Function to add records written by earlier chaincode versions to the indexes added since.
+# Each backfill transaction reads a batch of records and resumes where the last stopped.
+backfill_indexes() {
+    for BACKFILL in BackfillHashIndex BackfillAuditIndexes; do
+        show_progress "Running $BACKFILL until it completes..."
+        while true; do
+            LAST_COMMAND_OUTPUT=$(peer chaincode invoke \
+                -o localhost:7050 \
+                --ordererTLSHostnameOverride orderer.example.com \
+                --tls \
+                --cafile $FABRIC_SAMPLES_DIR/test-network/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem \
+                -C mychannel \
+                -n $CHAINCODE_NAME \
+                --peerAddresses localhost:7051 \
+                --tlsRootCertFiles $FABRIC_SAMPLES_DIR/test-network/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt \
+                --peerAddresses localhost:9051 \
+                --tlsRootCertFiles $FABRIC_SAMPLES_DIR/test-network/organizations/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt \
+                --waitForEvent \
+                -c "{\"function\":\"$BACKFILL\",\"Args\":[\"100\"]}" 2>&1)
+            check_status "$BACKFILL" || return 1
+
+            # The payload is the progress JSON, escaped by the peer CLI
+            if echo "$LAST_COMMAND_OUTPUT" | grep -q 'complete[^,}]*true'; then
+                break
+            fi
+        done
+    done
+    return 0
+}
 package_chaincode() {
     log "info" "Packaging chaincode..."
     
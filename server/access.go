@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Checks the caller's certificate attribute rules before submitting a transaction.
+// The chaincode enforces the same rules, but a refused transaction is never committed,
+// so the denial is recorded here with a RecordAccessDenial transaction of its own.
+// Writes the error response and returns false when the caller is refused.
+func authorizeTransaction(c *gin.Context, contract *client.Contract, transactionName string, targetID string) bool {
+	allowed, err := contract.EvaluateTransaction("CheckAccess", transactionName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to check access: %v", err)})
+		return false
+	}
+	if string(allowed) == "true" {
+		return true
+	}
+
+	if _, err := contract.SubmitTransaction("RecordAccessDenial", transactionName, targetID); err != nil {
+		log.Printf("WARNING: Failed to record access denial for %s on %s: %v\n", transactionName, targetID, err)
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("your certificate attributes do not allow %s", transactionName)})
+	return false
+}
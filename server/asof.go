@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"google.golang.org/protobuf/proto"
+)
+
+// Turns an asOf query value into the RFC3339 time the chaincode replays history to, keeping
+// fractional seconds so transactions later in the same second are not taken for earlier ones.
+// The value is either a time in RFC3339 or a block number, which stands for the time of
+// the latest transaction in that block.
+func resolveAsOf(network *client.Network, asOf string) (string, error) {
+	if parsed, err := time.Parse(time.RFC3339Nano, asOf); err == nil {
+		return parsed.UTC().Format(time.RFC3339Nano), nil
+	}
+
+	blockNumber, err := strconv.ParseUint(asOf, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("asOf must be an RFC3339 time or a block number, got %q", asOf)
+	}
+
+	blockTime, err := blockTimestamp(network, blockNumber)
+	if err != nil {
+		return "", err
+	}
+	return blockTime.Format(time.RFC3339Nano), nil
+}
+
+// Fetches a block from the query system chaincode and returns its latest transaction time
+func blockTimestamp(network *client.Network, blockNumber uint64) (time.Time, error) {
+	qscc := network.GetContract("qscc")
+	blockBytes, err := qscc.EvaluateTransaction("GetBlockByNumber", network.Name(), strconv.FormatUint(blockNumber, 10))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to fetch block %d: %v", blockNumber, err)
+	}
+
+	var block common.Block
+	if err := proto.Unmarshal(blockBytes, &block); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse block %d: %v", blockNumber, err)
+	}
+
+	var latest time.Time
+	for _, envelopeBytes := range block.GetData().GetData() {
+		var envelope common.Envelope
+		if err := proto.Unmarshal(envelopeBytes, &envelope); err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse transaction in block %d: %v", blockNumber, err)
+		}
+		var payload common.Payload
+		if err := proto.Unmarshal(envelope.GetPayload(), &payload); err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse transaction payload in block %d: %v", blockNumber, err)
+		}
+		var header common.ChannelHeader
+		if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), &header); err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse channel header in block %d: %v", blockNumber, err)
+		}
+
+		if txTime := header.GetTimestamp().AsTime(); txTime.After(latest) {
+			latest = txTime
+		}
+	}
+
+	if latest.IsZero() {
+		return time.Time{}, fmt.Errorf("block %d has no timestamped transactions", blockNumber)
+	}
+	return latest.UTC(), nil
+}
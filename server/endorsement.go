@@ -0,0 +1,68 @@
+package main
+
+import (
+	"dltfm/pkg/models"
+	"dltfm/pkg/models/policy"
+	"dltfm/server/gateway"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Submits a transaction that updates an existing file. The chaincode gives every file a
+// key-level endorsement policy over its RequiredOrgs, which the gateway does not account for
+// when picking endorsers, so the peers of those organizations are asked explicitly. The
+// submitting organization is included too, since the transaction's other writes still fall
+// under the chaincode-level policy.
+func submitFileTransaction(contract *client.Contract, mspID string, fileID string, transactionName string, args ...string) ([]byte, error) {
+	fileJSON, err := contract.EvaluateTransaction("GetFileByID", fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	var file models.File
+	if err := json.Unmarshal(fileJSON, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse file: %w", err)
+	}
+
+	endorsingOrgs := []string{mspID}
+	for _, org := range file.RequiredOrgs {
+		if org != mspID {
+			endorsingOrgs = append(endorsingOrgs, org)
+		}
+	}
+
+	return contract.Submit(transactionName,
+		client.WithArguments(args...),
+		client.WithEndorsingOrganizations(endorsingOrgs...),
+	)
+}
+
+// Submits an archive, delete or restore transaction. Applied to a whole version chain, it writes
+// every version, and each version has a key-level policy over its own RequiredOrgs; versions the
+// caller cannot read cannot be looked up, so every organization's peers endorse instead, as for
+// legal holds.
+func submitLifecycleTransaction(contract *client.Contract, mspID string, fileID string, wholeChain bool, transactionName string, args ...string) ([]byte, error) {
+	if !wholeChain {
+		return submitFileTransaction(contract, mspID, fileID, transactionName, args...)
+	}
+	return contract.Submit(transactionName,
+		client.WithArguments(args...),
+		client.WithEndorsingOrganizations(gateway.Organizations()...),
+	)
+}
+
+// Checks that an approval policy can be met through this server. The server approves for each
+// organization with a single identity, so a policy that needs several distinct signers from
+// one organization could only be satisfied by clients that sign with their own identities.
+func checkServerApprovable(expression string) error {
+	approvalPolicy, err := policy.Parse(expression)
+	if err != nil {
+		return fmt.Errorf("invalid policy %q: %v", expression, err)
+	}
+	if !approvalPolicy.SatisfiableOncePerOrg() {
+		return fmt.Errorf("policy %s needs several approvers from one organization, but the server approves for each organization with a single identity", approvalPolicy)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"dltfm/pkg/models"
+	"dltfm/server/gateway"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Default time between expiry sweeps, override with EXPIRY_SWEEP_INTERVAL (e.g. "10m")
+const defaultExpirySweepInterval = 5 * time.Minute
+
+// Periodically submits ExpirePendingFiles so overdue files move to EXPIRED without anyone asking.
+// The sweep runs as EXPIRY_SWEEP_MSP, defaulting to Org1MSP; any organization may expire files.
+func runExpirySweeper(gatewayManager *GatewayManager) {
+	interval := defaultExpirySweepInterval
+	if value := os.Getenv("EXPIRY_SWEEP_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: Invalid EXPIRY_SWEEP_INTERVAL %q, using %s\n", value, interval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	mspID := os.Getenv("EXPIRY_SWEEP_MSP")
+	if mspID == "" {
+		mspID = "Org1MSP"
+	}
+
+	log.Printf("Expiry sweeper running every %s as %s\n", interval, mspID)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sweepExpiredFiles(gatewayManager, mspID)
+	}
+}
+
+// Runs a single ExpirePendingFiles transaction and logs what it expired
+func sweepExpiredFiles(gatewayManager *GatewayManager, mspID string) {
+	gw, err := gatewayManager.GetGateway(mspID)
+	if err != nil {
+		log.Printf("ERROR: Expiry sweep failed to get gateway: %v\n", err)
+		return
+	}
+
+	network := gw.GetNetwork("mychannel")
+	contract := network.GetContract("chaincode")
+
+	// Any file may expire, so ask every organization to endorse in case it is in a file's key-level policy
+	result, err := contract.Submit("ExpirePendingFiles",
+		client.WithEndorsingOrganizations(gateway.Organizations()...),
+	)
+	if err != nil {
+		log.Printf("ERROR: Expiry sweep failed: %v\n", err)
+		return
+	}
+
+	var report models.ExpiryReport
+	if err := json.Unmarshal(result, &report); err != nil {
+		log.Printf("ERROR: Failed to parse expiry report: %v\n", err)
+		return
+	}
+
+	if report.Count > 0 {
+		log.Printf("Expiry sweep expired %d files: %v\n", report.Count, report.Expired)
+	} else {
+		log.Println("Expiry sweep found no overdue files")
+	}
+}
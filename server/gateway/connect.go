@@ -25,6 +25,11 @@ type OrgConfig struct {
 	CryptoPath string
 }
 
+// Organizations lists the MSP IDs the server can connect as, one per peer organization of the network
+func Organizations() []string {
+	return []string{"Org1MSP", "Org2MSP"}
+}
+
 func getOrgConfig(mspID string) OrgConfig {
 	switch mspID {
 	case "Org2MSP":
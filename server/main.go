@@ -1,7 +1,7 @@
 package main
 
 import (
-	"crypto/sha256"
+	"dltfm/pkg/models"
 	"dltfm/server/gateway"
 	"dltfm/server/ipfs"
 	"dltfm/server/middleware"
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	// "os"
@@ -23,6 +24,12 @@ import (
 	"github.com/hyperledger/fabric-gateway/pkg/client"
 )
 
+// Page size limits for paginated file listings
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
 type GatewayManager struct {
 	gateways map[string]*client.Gateway
 	mu       sync.RWMutex
@@ -70,13 +77,6 @@ func (gm *GatewayManager) Close() {
 	}
 }
 
-// Generate a unique file ID based on name and timestamp
-func GenerateFileID(name string, timestamp string) string {
-	data := fmt.Sprintf("%s_%s", name, timestamp)
-	hash := sha256.Sum256([]byte(data))
-	return fmt.Sprintf("%x", hash[:8]) // Take first 8 bytes
-}
-
 func main() {
 	// Initialize Supabase Client
 	supabaseClient, err := supabase.NewClient()
@@ -88,6 +88,9 @@ func main() {
 	gatewayManager := NewGatewayManager()
 	defer gatewayManager.Close()
 
+	// Expire pending files whose approval deadline has passed
+	go runExpirySweeper(gatewayManager)
+
 	r := gin.Default()
 
 	// Update CORS configuration to allow Organization headers
@@ -109,7 +112,7 @@ func main() {
 	api := r.Group("/api")
 	api.Use(middleware.AuthRequired(supabaseClient))
 	{
-		// Fetch all files
+		// Fetch a page of files, filtered by the query string
 		api.GET("/files", func(c *gin.Context) {
 			userID := c.GetString("userID")
 			mspID := c.GetString("mspID")
@@ -127,22 +130,108 @@ func main() {
 			network := gw.GetNetwork("mychannel")
 			contract := network.GetContract("chaincode")
 
-			result, err := contract.EvaluateTransaction("QueryAllFiles")
+			// Build the page request from the query string
+			pageSize := defaultPageSize
+			if limit := c.Query("limit"); limit != "" {
+				parsed, err := strconv.Atoi(limit)
+				if err != nil || parsed <= 0 {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+					return
+				}
+				pageSize = min(parsed, maxPageSize)
+			}
+
+			filter := models.FileFilter{
+				Owner:           c.Query("owner"),
+				Status:          c.Query("status"),
+				EndorsementType: c.Query("endorsementType"),
+				MimeType:        c.Query("mimeType"),
+				Lifecycle:       c.Query("lifecycle"), // ARCHIVED, DELETED or ALL to include retired files
+			}
+			filterJSON, err := json.Marshal(filter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to marshal filter: %v", err)})
+				return
+			}
+
+			var result []byte
+			var asOfTime string
+			if asOf := c.Query("asOf"); asOf != "" {
+				// The registry as it was at a time or block, for disputes
+				asOfTime, err = resolveAsOf(network, asOf)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				result, err = contract.EvaluateTransaction("QueryFilesAsOfPage",
+					asOfTime,
+					strconv.Itoa(pageSize),
+					c.Query("cursor"),
+					string(filterJSON),
+				)
+			} else {
+				result, err = contract.EvaluateTransaction("QueryFilesPage",
+					strconv.Itoa(pageSize),
+					c.Query("cursor"),
+					string(filterJSON),
+				)
+			}
 			if err != nil {
 				fmt.Printf("Error during evaluation: %v\n", err)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to query files: %v", err)})
 				return
 			}
 
-			var files []interface{}
-			if err := json.Unmarshal(result, &files); err != nil {
+			var page models.FilePage
+			if err := json.Unmarshal(result, &page); err != nil {
 				fmt.Printf("Error unmarshaling result: %v\n", err)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse response"})
 				return
 			}
 
-			fmt.Printf("Successfully retrieved %d files for org %s\n", len(files), org.Name)
-			c.JSON(http.StatusOK, files)
+			fmt.Printf("Successfully retrieved %d files for org %s\n", len(page.Files), org.Name)
+			c.JSON(http.StatusOK, gin.H{
+				"files":    page.Files,
+				"bookmark": page.Bookmark,
+			})
+		})
+
+		// Fetch a single file, optionally as it was at ?asOf= (RFC3339 time or block number)
+		api.GET("/files/:id", func(c *gin.Context) {
+			mspID := c.GetString("mspID")
+			fileID := c.Param("id")
+
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			var result []byte
+			var asOfTime string
+			if asOf := c.Query("asOf"); asOf != "" {
+				asOfTime, err = resolveAsOf(network, asOf)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				result, err = contract.EvaluateTransaction("GetFileByIDAsOf", fileID, asOfTime)
+			} else {
+				result, err = contract.EvaluateTransaction("GetFileByID", fileID)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch file: %v", err)})
+				return
+			}
+			if len(result) == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("file %s not found", fileID)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
 		})
 
 		// Fetch file versions
@@ -174,6 +263,97 @@ func main() {
 			c.JSON(http.StatusOK, json.RawMessage(result))
 		})
 
+		// Fetch the confidential details of a private file, only available to organizations sharing its collection
+		api.GET("/files/:id/private", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			fileID := c.Param("id")
+
+			fmt.Printf("Request for private details of file: %s, user: %s, org: %s (MSP: %s)\n", fileID, userID, org.Name, mspID)
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("GetFilePrivateDetails", fileID)
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("failed to fetch private details: %v", err)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
+		// Fetch the newest version reachable from a file
+		api.GET("/files/:id/versions/latest", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			fileID := c.Param("id")
+
+			fmt.Printf("Request for latest version of file: %s, user: %s, org: %s (MSP: %s)\n", fileID, userID, org.Name, mspID)
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("GetLatestVersion", fileID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch latest version: %v", err)})
+				return
+			}
+			if len(result) == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
+		// Fetch the versions registered directly on top of a file
+		api.GET("/files/:id/versions/next", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			fileID := c.Param("id")
+
+			fmt.Printf("Request for next versions of file: %s, user: %s, org: %s (MSP: %s)\n", fileID, userID, org.Name, mspID)
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("GetNextVersions", fileID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch next versions: %v", err)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
 		// Getting audit
 		api.GET("/files/:id/audit", func(c *gin.Context) {
 			userID := c.GetString("userID")
@@ -203,6 +383,119 @@ func main() {
 			c.JSON(http.StatusOK, json.RawMessage(result))
 		})
 
+		// Verifying the hash chain of a file's audit trail
+		api.GET("/files/:id/audit/verify", func(c *gin.Context) {
+			mspID := c.GetString("mspID")
+			fileID := c.Param("id")
+
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("VerifyAuditChain", fileID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to verify audit chain: %v", err)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
+		// Ledger provenance, every committed state of a file with field-level diffs
+		api.GET("/files/:id/history", func(c *gin.Context) {
+			mspID := c.GetString("mspID")
+			fileID := c.Param("id")
+
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("GetFileHistory", fileID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch file history: %v", err)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
+		// Searching the audit trail across files
+		api.GET("/audit", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			fmt.Printf("Audit search from user: %s, organization: %s (MSP: %s)\n", userID, org.Name, mspID)
+
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			pageSize := defaultPageSize
+			if limit := c.Query("limit"); limit != "" {
+				parsed, err := strconv.Atoi(limit)
+				if err != nil || parsed <= 0 {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+					return
+				}
+				pageSize = min(parsed, maxPageSize)
+			}
+
+			filter := models.AuditFilter{
+				FileID: c.Query("fileId"),
+				OrgID:  c.Query("org"),
+				UserID: c.Query("user"), // Fabric client ID, the same for every web user of an organization
+				Action: c.Query("action"),
+				From:   c.Query("from"), // RFC3339, inclusive
+				To:     c.Query("to"),
+			}
+			if err := filter.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			filterJSON, err := json.Marshal(filter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to marshal filter: %v", err)})
+				return
+			}
+
+			result, err := contract.EvaluateTransaction("QueryAuditLogs",
+				string(filterJSON),
+				strconv.Itoa(pageSize),
+				c.Query("cursor"),
+			)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to query audit logs: %v", err)})
+				return
+			}
+
+			var page models.AuditLogPage
+			if err := json.Unmarshal(result, &page); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse response"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"logs":     page.Logs,
+				"bookmark": page.Bookmark,
+			})
+		})
+
 		// Get file content
 		api.GET("/files/:id/content", func(c *gin.Context) {
 			userID := c.GetString("userID")
@@ -224,6 +517,18 @@ func main() {
 			network := gw.GetNetwork("mychannel")
 			contract := network.GetContract("chaincode")
 
+			// Refuse before touching IPFS if the ledger says this organization may not read the file
+			canRead, err := contract.EvaluateTransaction("CanReadFile", fileID)
+			if err != nil {
+				fmt.Printf("DEBUG: Error checking read access: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to check read access: %v", err)})
+				return
+			}
+			if string(canRead) != "true" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you do not have read access to this file"})
+				return
+			}
+
 			fileJSON, err := contract.EvaluateTransaction("GetFileByID", fileID)
 			if err != nil {
 				fmt.Printf("DEBUG: Error getting file from blockchain: %v\n", err)
@@ -248,20 +553,12 @@ func main() {
 
 			fmt.Printf("DEBUG: Parsed file data - IPFS CID: %s, Name: %s\n", file.IPFSLocation, file.Name)
 
-			// Define a struct to parse the metadata JSON
-			type FileMetadata struct {
-				Size      int64  `json:"size"`
-				Type      string `json:"type"`
-				CreatedAt string `json:"createdAt"`
-				Encoding  string `json:"encoding"`
-			}
-
 			// Unmarshal the metadata from the file
-			var meta FileMetadata
-			if err := json.Unmarshal([]byte(file.Metadata), &meta); err != nil {
+			meta, err := models.ParseMetadata(file.Metadata)
+			if err != nil {
 				fmt.Printf("DEBUG: Error parsing metadata: %v for metadata: %s\n", err, file.Metadata)
 				// Fallback if metadata cannot be parsed
-				meta.Type = "application/octet-stream"
+				meta = &models.FileMetadata{Type: "application/octet-stream"}
 			} else {
 				fmt.Printf("DEBUG: Parsed metadata - Type: %s, Size: %d\n", meta.Type, meta.Size)
 			}
@@ -295,6 +592,78 @@ func main() {
 			c.Data(http.StatusOK, contentType, content)
 		})
 
+		// Run a CouchDB rich query over file fields
+		api.POST("/files/query", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			fmt.Printf("Rich query request from user: %s, organization: %s (MSP: %s)\n", userID, org.Name, mspID)
+
+			var request struct {
+				Selector map[string]interface{} `json:"selector"`
+				Limit    int                    `json:"limit"`
+				Cursor   string                 `json:"cursor"`
+			}
+
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+				return
+			}
+
+			// Reject selectors on fields outside the allow-list before they reach the peers
+			if err := models.ValidateSelector(request.Selector); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid selector: %v", err)})
+				return
+			}
+
+			pageSize := defaultPageSize
+			if request.Limit < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			if request.Limit > 0 {
+				pageSize = min(request.Limit, maxPageSize)
+			}
+
+			selectorJSON, err := json.Marshal(request.Selector)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to marshal selector: %v", err)})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("QueryFiles",
+				string(selectorJSON),
+				strconv.Itoa(pageSize),
+				request.Cursor,
+			)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to query files: %v", err)})
+				return
+			}
+
+			var page models.FilePage
+			if err := json.Unmarshal(result, &page); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse response"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"files":    page.Files,
+				"bookmark": page.Bookmark,
+			})
+		})
+
 		// Register a new file
 		api.POST("/files", func(c *gin.Context) {
 			userID := c.GetString("userID")
@@ -304,15 +673,22 @@ func main() {
 			fmt.Printf("Upload request from user: %s, organization: %s (MSP: %s)\n", userID, org.Name, mspID)
 
 			var request struct {
-				ID                string `json:"id"`
-				Name              string `json:"name"`
-				Content           string `json:"content"` // This will be base64 content from client
-				Owner             string `json:"owner"`
-				Metadata          string `json:"metadata"`
-				PreviousID        string `json:"previousID"`
+				Name              string            `json:"name"`
+				Content           string            `json:"content"` // This will be base64 content from client
+				Owner             string            `json:"owner"`
+				Metadata          string            `json:"metadata"`
+				PreviousID        string            `json:"previousID"`
+				DuplicatePolicy   string            `json:"duplicatePolicy"` // REJECT, ALLOW or LINK
+				VersionPolicy     string            `json:"versionPolicy"`   // LINEAR, or FORK to branch a version that already has a successor
+				Readers           []models.ReadRule `json:"readers"`         // Who besides the owner and approvers may read the file
 				EndorsementConfig struct {
 					PolicyType   string   `json:"policyType"`
 					RequiredOrgs []string `json:"requiredOrgs"`
+					Policy       string   `json:"policy,omitempty"`      // e.g. OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP') with policyType CUSTOM, naming only registered organizations
+					Workflow     string   `json:"workflow,omitempty"`    // Workflow ID with policyType WORKFLOW
+					Deadline     string   `json:"deadline,omitempty"`    // RFC3339 approval deadline
+					Private      bool     `json:"private,omitempty"`     // Keep the metadata in a private data collection
+					RetainUntil  string   `json:"retainUntil,omitempty"` // RFC3339, the file cannot be archived or deleted before then
 				} `json:"endorsementConfig"`
 			}
 
@@ -321,6 +697,20 @@ func main() {
 				return
 			}
 
+			// The chaincode rejects bad metadata too, check it before anything is pinned
+			if _, err := models.NormalizeMetadata(request.Metadata); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid metadata: %v", err)})
+				return
+			}
+
+			// Signer quorums above one per organization can never be met through this server
+			if request.EndorsementConfig.PolicyType == "CUSTOM" {
+				if err := checkServerApprovable(request.EndorsementConfig.Policy); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+
 			// Decode base64 content
 			contentBytes, err := base64.StdEncoding.DecodeString(request.Content)
 			if err != nil {
@@ -350,6 +740,11 @@ func main() {
 			network := gw.GetNetwork("mychannel")
 			contract := network.GetContract("chaincode")
 
+			// Denials of new files are audited under the version they would extend, if any
+			if !authorizeTransaction(c, contract, "RegisterFile", request.PreviousID) {
+				return
+			}
+
 			// Convert endorsement config to JSON string
 			endorsementConfigJSON, err := json.Marshal(request.EndorsementConfig)
 			if err != nil {
@@ -359,19 +754,57 @@ func main() {
 				return
 			}
 
-			// Now pass IPFS CID instead of content
-			_, err = contract.SubmitTransaction("RegisterFile",
-				request.ID,
+			var readersArg string
+			if len(request.Readers) > 0 {
+				readersJSON, err := json.Marshal(request.Readers)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": fmt.Sprintf("failed to marshal readers: %v", err),
+					})
+					return
+				}
+				readersArg = string(readersJSON)
+			}
+
+			// Private owner names and metadata travel as transient data so they never land in the transaction.
+			// Only this organization's peers endorse, keeping the transient data away from the others.
+			ownerArg, metadataArg := org.Name, request.Metadata
+			proposalOptions := []client.ProposalOption{}
+			if request.EndorsementConfig.Private {
+				ownerArg, metadataArg = "", ""
+				proposalOptions = append(proposalOptions,
+					client.WithTransient(map[string][]byte{
+						"owner":    []byte(org.Name),
+						"metadata": []byte(request.Metadata),
+					}),
+					client.WithEndorsingOrganizations(mspID),
+				)
+			}
+
+			// Now pass IPFS CID instead of content.
+			// The chaincode derives the file ID from the transaction, client IDs are never trusted.
+			proposalOptions = append(proposalOptions, client.WithArguments(
+				"",
 				request.Name,
 				ipfsCID, // Pass IPFS CID instead of content
-				org.Name,
-				request.Metadata,
+				ownerArg,
+				metadataArg,
 				request.PreviousID,
 				string(endorsementConfigJSON),
-			)
+				request.DuplicatePolicy,
+				readersArg,
+				request.VersionPolicy,
+			))
+			fileID, err := contract.Submit("RegisterFile", proposalOptions...)
 
 			if err != nil {
 				log.Printf("ERROR: Failed to register file: %v\n", err)
+				if models.IsVersionConflict(err) || models.IsIDConflict(err) {
+					c.JSON(http.StatusConflict, gin.H{
+						"error": fmt.Sprintf("failed to register file: %v", err),
+					})
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": fmt.Sprintf("failed to register file: %v", err),
 				})
@@ -380,11 +813,13 @@ func main() {
 
 			c.JSON(http.StatusOK, gin.H{
 				"message": "File successfully registered",
-				"id":      request.ID,
+				"id":      string(fileID),
 				"ipfsCID": ipfsCID, // Return the IPFS CID for client reference
 			})
 		})
 
+		// Approve a file for the caller's organization. The approval is signed with the organization's
+		// gateway identity, so a Signers(n) quorum cannot be met by several web users of one organization.
 		api.POST("/files/:id/approve", func(c *gin.Context) {
 			userID := c.GetString("userID")
 			mspID := c.GetString("mspID")
@@ -406,7 +841,11 @@ func main() {
 			network := gw.GetNetwork("mychannel")
 			contract := network.GetContract("chaincode")
 
-			_, err = contract.SubmitTransaction("ApproveFile", fileID)
+			if !authorizeTransaction(c, contract, "ApproveFile", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "ApproveFile", fileID)
 			if err != nil {
 				log.Printf("ERROR: Failed to approve file: %v\n", err)
 				c.JSON(http.StatusInternalServerError, gin.H{
@@ -421,6 +860,937 @@ func main() {
 			})
 		})
 
+		// Replace who may read a file, only the owning organization may do this
+		// Attribute rules are checked against the organization's Admin identity the server submits with,
+		// so they only admit users who query with their own Fabric identity
+		api.PUT("/files/:id/readers", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Readers update for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				Readers []models.ReadRule `json:"readers"`
+			}
+
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+				return
+			}
+
+			readersJSON, err := json.Marshal(request.Readers)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to marshal readers: %v", err)})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "UpdateReaders", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "UpdateReaders", fileID, string(readersJSON))
+			if err != nil {
+				log.Printf("ERROR: Failed to update readers: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to update readers: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Readers successfully updated",
+				"id":      fileID,
+			})
+		})
+
+		// Choose who besides the owner may register new versions of a file
+		api.PUT("/files/:id/editors", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Editors update for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				Editors []models.ReadRule `json:"editors"`
+			}
+
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+				return
+			}
+
+			editorsJSON, err := json.Marshal(request.Editors)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to marshal editors: %v", err)})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "UpdateEditors", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "UpdateEditors", fileID, string(editorsJSON))
+			if err != nil {
+				log.Printf("ERROR: Failed to update editors: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to update editors: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Editors successfully updated",
+				"id":      fileID,
+			})
+		})
+
+		api.POST("/files/:id/reject", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Rejection request for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				Reason string `json:"reason"`
+			}
+
+			if err := c.BindJSON(&request); err != nil || request.Reason == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "RejectFile", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "RejectFile", fileID, request.Reason)
+			if err != nil {
+				log.Printf("ERROR: Failed to reject file: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to reject file: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "File successfully rejected",
+				"id":      fileID,
+			})
+		})
+
+		api.POST("/files/:id/revoke", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Revocation request for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				Reason string `json:"reason"`
+			}
+
+			if err := c.BindJSON(&request); err != nil || request.Reason == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "RevokeApproval", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "RevokeApproval", fileID, request.Reason)
+			if err != nil {
+				log.Printf("ERROR: Failed to revoke approval: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to revoke approval: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Approval successfully revoked",
+				"id":      fileID,
+			})
+		})
+
+		// Archive a file, or its whole version chain, hiding it from default listings
+		api.POST("/files/:id/archive", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Archive request for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				Reason string `json:"reason"`
+				Chain  bool   `json:"chain"` // Archive every version of the file
+			}
+
+			if err := c.BindJSON(&request); err != nil || request.Reason == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "ArchiveFile", fileID) {
+				return
+			}
+
+			_, err = submitLifecycleTransaction(contract, mspID, fileID, request.Chain, "ArchiveFile", fileID, request.Reason, strconv.FormatBool(request.Chain))
+			if err != nil {
+				log.Printf("ERROR: Failed to archive file: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to archive file: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "File successfully archived",
+				"id":      fileID,
+			})
+		})
+
+		// Delete a file, or its whole version chain. The ledger keeps a tombstone; with unpin set,
+		// content that no remaining file refers to is also unpinned from IPFS.
+		api.DELETE("/files/:id", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Delete request for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				Reason string `json:"reason"`
+				Chain  bool   `json:"chain"` // Delete every version of the file
+				Unpin  bool   `json:"unpin"` // Unpin content no live file refers to any more
+			}
+
+			if err := c.BindJSON(&request); err != nil || request.Reason == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "DeleteFile", fileID) {
+				return
+			}
+
+			result, err := submitLifecycleTransaction(contract, mspID, fileID, request.Chain, "DeleteFile", fileID, request.Reason, strconv.FormatBool(request.Chain))
+			if err != nil {
+				log.Printf("ERROR: Failed to delete file: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to delete file: %v", err),
+				})
+				return
+			}
+
+			var report models.DeletionReport
+			if err := json.Unmarshal(result, &report); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse deletion report"})
+				return
+			}
+
+			// The deletion is already committed, so unpinning failures are only logged
+			unpinned, held := []string{}, []string{}
+			if request.Unpin {
+				unpinned, held = unpinUnheldContent(contract, report.Unreferenced)
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message":  "File successfully deleted",
+				"deleted":  report.Deleted,
+				"unpinned": unpinned,
+				"held":     held,
+			})
+		})
+
+		api.POST("/files/:id/restore", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Restore request for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			// Restoring a single version needs no body
+			var request struct {
+				Chain bool `json:"chain"` // Restore every retired version of the file
+			}
+			if c.Request.ContentLength > 0 {
+				if err := c.BindJSON(&request); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+					return
+				}
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "RestoreFile", fileID) {
+				return
+			}
+
+			_, err = submitLifecycleTransaction(contract, mspID, fileID, request.Chain, "RestoreFile", fileID, strconv.FormatBool(request.Chain))
+			if err != nil {
+				log.Printf("ERROR: Failed to restore file: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to restore file: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "File successfully restored",
+				"id":      fileID,
+			})
+		})
+
+		// Extend how long a file must be kept
+		api.PUT("/files/:id/retention", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Retention update for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				RetainUntil string `json:"retainUntil"` // RFC3339
+			}
+
+			if err := c.BindJSON(&request); err != nil || request.RetainUntil == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A retention date is required"})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "SetRetention", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "SetRetention", fileID, request.RetainUntil)
+			if err != nil {
+				log.Printf("ERROR: Failed to set retention: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to set retention: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message":     "Retention successfully updated",
+				"id":          fileID,
+				"retainUntil": request.RetainUntil,
+			})
+		})
+
+		// Place a legal hold, only designated organizations may
+		api.POST("/files/:id/holds", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Legal hold request for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				HoldID string `json:"holdId"` // Matter or case reference
+				Reason string `json:"reason"`
+			}
+
+			if err := c.BindJSON(&request); err != nil || request.HoldID == "" || request.Reason == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A hold ID and a reason are required"})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "PlaceLegalHold", fileID) {
+				return
+			}
+
+			_, err = submitHoldTransaction(contract, "PlaceLegalHold", fileID, request.HoldID, request.Reason)
+			if err != nil {
+				log.Printf("ERROR: Failed to place legal hold: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to place legal hold: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Legal hold successfully placed",
+				"id":      fileID,
+				"holdId":  request.HoldID,
+			})
+		})
+
+		// Every user of an organization submits as its Admin identity, which the chaincode lets release
+		// any hold the organization placed, so releases are limited to organization admins here
+		api.DELETE("/files/:id/holds/:holdId", middleware.AdminRequired(), func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+			holdID := c.Param("holdId")
+
+			fmt.Printf("Legal hold release %s for file %s from user: %s, organization: %s (MSP: %s)\n",
+				holdID, fileID, userID, org.Name, mspID)
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "ReleaseLegalHold", fileID) {
+				return
+			}
+
+			_, err = submitHoldTransaction(contract, "ReleaseLegalHold", fileID, holdID)
+			if err != nil {
+				log.Printf("ERROR: Failed to release legal hold: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to release legal hold: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Legal hold successfully released",
+				"id":      fileID,
+				"holdId":  holdID,
+			})
+		})
+
+		// Offer a file to another organization, which has to accept before ownership moves
+		api.POST("/files/:id/transfer", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Transfer request for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			var request struct {
+				NewOwnerMSP string `json:"newOwnerMsp"`
+			}
+
+			if err := c.BindJSON(&request); err != nil || request.NewOwnerMSP == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "The receiving organization's MSP ID is required"})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "ProposeTransfer", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "ProposeTransfer", fileID, request.NewOwnerMSP)
+			if err != nil {
+				log.Printf("ERROR: Failed to propose transfer: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to propose transfer: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Transfer successfully proposed",
+				"id":      fileID,
+				"toMsp":   request.NewOwnerMSP,
+			})
+		})
+
+		// Transfers offered to the caller's organization
+		api.GET("/transfers", func(c *gin.Context) {
+			mspID := c.GetString("mspID")
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("GetIncomingTransfers")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch transfers: %v", err)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
+		api.POST("/transfers/:id/accept", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Transfer acceptance for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "AcceptTransfer", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "AcceptTransfer", fileID)
+			if err != nil {
+				log.Printf("ERROR: Failed to accept transfer: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to accept transfer: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Transfer successfully accepted",
+				"id":      fileID,
+			})
+		})
+
+		api.POST("/transfers/:id/decline", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+			fileID := c.Param("id")
+
+			fmt.Printf("Transfer decline for file %s from user: %s, organization: %s (MSP: %s)\n",
+				fileID, userID, org.Name, mspID)
+
+			// The reason is optional
+			var request struct {
+				Reason string `json:"reason"`
+			}
+			if c.Request.ContentLength > 0 {
+				if err := c.BindJSON(&request); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+					return
+				}
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			if !authorizeTransaction(c, contract, "DeclineTransfer", fileID) {
+				return
+			}
+
+			_, err = submitFileTransaction(contract, mspID, fileID, "DeclineTransfer", fileID, request.Reason)
+			if err != nil {
+				log.Printf("ERROR: Failed to decline transfer: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to decline transfer: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Transfer successfully declined",
+				"id":      fileID,
+			})
+		})
+
+		// Define a multi-stage approval workflow that files can reference
+		api.POST("/workflows", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			fmt.Printf("Workflow definition request from user: %s, organization: %s (MSP: %s)\n", userID, org.Name, mspID)
+
+			var request struct {
+				ID     string                 `json:"id"`
+				Name   string                 `json:"name"`
+				Stages []models.WorkflowStage `json:"stages"`
+			}
+
+			if err := c.BindJSON(&request); err != nil || request.ID == "" || len(request.Stages) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A workflow ID and at least one stage are required"})
+				return
+			}
+			for _, stage := range request.Stages {
+				if err := checkServerApprovable(stage.Policy); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("stage %s: %v", stage.Name, err)})
+					return
+				}
+			}
+
+			stagesJSON, err := json.Marshal(request.Stages)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to marshal stages: %v", err)})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			// Workflows are not files, so a denial is filed under no file
+			if !authorizeTransaction(c, contract, "CreateWorkflow", "") {
+				return
+			}
+
+			_, err = contract.SubmitTransaction("CreateWorkflow", request.ID, request.Name, string(stagesJSON))
+			if err != nil {
+				log.Printf("ERROR: Failed to create workflow: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to create workflow: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Workflow successfully created",
+				"id":      request.ID,
+			})
+		})
+
+		api.GET("/workflows/:id", func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			workflowID := c.Param("id")
+
+			fmt.Printf("Request for workflow: %s, user: %s, org: %s (MSP: %s)\n", workflowID, userID, org.Name, mspID)
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("GetWorkflow", workflowID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch workflow: %v", err)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
+		// Replace the certificate attribute rules of the caller's organization, admins only.
+		// The server submits as the organization's Admin identity, which passes the chaincode's admin
+		// check for every user, so the route itself is limited to organization admins in Supabase.
+		// The rules only bind clients that submit with their own Fabric identity.
+		api.PUT("/access-policy", middleware.AdminRequired(), func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			fmt.Printf("Access policy update from user: %s, organization: %s (MSP: %s)\n", userID, org.Name, mspID)
+
+			var request struct {
+				Rules map[string][]models.AttributeRule `json:"rules"` // e.g. {"ApproveFile": [{"attribute": "role", "value": "approver"}]}
+			}
+
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+				return
+			}
+
+			rulesJSON, err := json.Marshal(request.Rules)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to marshal rules: %v", err)})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			_, err = contract.SubmitTransaction("SetAccessPolicy", string(rulesJSON))
+			if err != nil {
+				log.Printf("ERROR: Failed to set access policy: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to set access policy: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Access policy successfully updated",
+				"mspId":   mspID,
+			})
+		})
+
+		api.GET("/access-policy/:mspId", func(c *gin.Context) {
+			mspID := c.GetString("mspID")
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("GetAccessPolicy", c.Param("mspId"))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch access policy: %v", err)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
+		// Replace the organizations that may place legal holds on the caller's organization's files, admins only
+		api.PUT("/legal-hold-orgs", middleware.AdminRequired(), func(c *gin.Context) {
+			userID := c.GetString("userID")
+			mspID := c.GetString("mspID")
+			org := c.MustGet("organization").(*supabase.Organization)
+
+			fmt.Printf("Legal hold organizations update from user: %s, organization: %s (MSP: %s)\n", userID, org.Name, mspID)
+
+			var request struct {
+				Holders []string `json:"holders"` // e.g. ["Org1MSP"]
+			}
+
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+				return
+			}
+
+			holdersJSON, err := json.Marshal(request.Holders)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to marshal holders: %v", err)})
+				return
+			}
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to get gateway: %v", err),
+				})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			_, err = contract.SubmitTransaction("SetLegalHoldOrgs", string(holdersJSON))
+			if err != nil {
+				log.Printf("ERROR: Failed to set legal hold organizations: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("failed to set legal hold organizations: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Legal hold organizations successfully updated",
+				"mspId":   mspID,
+			})
+		})
+
+		api.GET("/legal-hold-orgs/:mspId", func(c *gin.Context) {
+			mspID := c.GetString("mspID")
+
+			// Get the appropriate gateway for this organization
+			gw, err := gatewayManager.GetGateway(mspID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get gateway: %v", err)})
+				return
+			}
+
+			network := gw.GetNetwork("mychannel")
+			contract := network.GetContract("chaincode")
+
+			result, err := contract.EvaluateTransaction("GetLegalHoldOrgs", c.Param("mspId"))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to fetch legal hold organizations: %v", err)})
+				return
+			}
+
+			c.JSON(http.StatusOK, json.RawMessage(result))
+		})
+
 	}
 
 	log.Println("Starting server on :8080...")
@@ -65,6 +65,22 @@ func AuthRequired(supabaseClient *supabase.Client) gin.HandlerFunc {
 		c.Set("organization", validOrg)
 		c.Set("orgName", validOrg.Name)
 		c.Set("mspID", validOrg.FabricMSPID)
+		c.Set("orgRole", validOrg.Role)
+
+		c.Next()
+	}
+}
+
+// Role in user_organizations that may administer an organization
+const adminRole = "admin"
+
+// AdminRequired limits a route to admins of the organization chosen by AuthRequired
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("orgRole") != adminRole {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only organization admins can do this"})
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"dltfm/server/gateway"
+	"dltfm/server/ipfs"
+	"log"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Unpins content from IPFS unless a legal hold covers it. The ledger is asked again right
+// before each unpin, since a hold may have been placed after the file was deleted.
+// Returns the CIDs that were unpinned and those refused because they are held.
+func unpinUnheldContent(contract *client.Contract, cids []string) (unpinned []string, held []string) {
+	unpinned, held = []string{}, []string{}
+	ipfsClient := ipfs.NewIPFSClient("localhost:5001", false)
+
+	for _, cid := range cids {
+		result, err := contract.EvaluateTransaction("IsContentHeld", cid)
+		if err != nil {
+			// Without an answer the content is treated as held
+			log.Printf("WARNING: Failed to check legal holds on %s, keeping it pinned: %v\n", cid, err)
+			held = append(held, cid)
+			continue
+		}
+		if string(result) == "true" {
+			log.Printf("Refusing to unpin %s, it is under legal hold\n", cid)
+			held = append(held, cid)
+			continue
+		}
+
+		if err := ipfsClient.UnpinFile(cid); err != nil {
+			log.Printf("WARNING: Failed to unpin %s: %v\n", cid, err)
+			continue
+		}
+		unpinned = append(unpinned, cid)
+	}
+
+	return unpinned, held
+}
+
+// Submits a legal hold transaction. Legal may hold files it is not allowed to read, so the file's
+// required organizations cannot be looked up first; every organization's peers endorse instead,
+// which satisfies any file's key-level endorsement policy.
+func submitHoldTransaction(contract *client.Contract, transactionName string, args ...string) ([]byte, error) {
+	return contract.Submit(transactionName,
+		client.WithArguments(args...),
+		client.WithEndorsingOrganizations(gateway.Organizations()...),
+	)
+}
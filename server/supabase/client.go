@@ -35,6 +35,7 @@ type Organization struct {
 	FabricMSPID string    `json:"fabric_msp_id"`
 	CAURL       string    `json:"ca_url"`
 	CreatedAt   time.Time `json:"created_at"`
+	Role        string    `json:"role,omitempty"` // The user's role in the organization, from user_organizations
 }
 
 type UserOrganization struct {
@@ -47,7 +48,7 @@ type UserOrganization struct {
 // Add this new method to your Client struct
 func (c *Client) GetUserOrganizations(userID string) ([]Organization, error) {
 	req, err := http.NewRequest("GET",
-		fmt.Sprintf("%s/rest/v1/user_organizations?user_id=eq.%s&select=organizations(*)", c.projectURL, userID),
+		fmt.Sprintf("%s/rest/v1/user_organizations?user_id=eq.%s&select=role,organizations(*)", c.projectURL, userID),
 		nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -76,6 +77,7 @@ func (c *Client) GetUserOrganizations(userID string) ([]Organization, error) {
 	organizations := make([]Organization, len(userOrgs))
 	for i, userOrg := range userOrgs {
 		organizations[i] = userOrg.Org
+		organizations[i].Role = userOrg.Role
 	}
 
 	return organizations, nil
@@ -13,7 +13,6 @@ import { useOrg } from '@/contexts/OrgContext';;
 import OrganizationSelector from '@/components/OrgSelector';
 import { supabase } from '@/lib/supabase';
 import OrganizationOnboarding from '@/components/OrganizationOnboarding';
-import { v4 as uuidv4 } from 'uuid';
 import { EndorsementWizard } from '@/components/EndorsementWizard';
 import {
   Command,
@@ -53,14 +52,22 @@ export default function Home() {
     
     try {
       setLoading(true);
-      const response = await axios.get<BlockchainFile[]>('http://localhost:8080/api/files', {
-        headers: {
-          Authorization: `Bearer ${session?.access_token}`,
-          'X-Organization-ID': currentOrg?.id,
-          'X-MSP-ID': currentOrg?.fabric_msp_id
-        }
-      });
-      setFiles(response.data);
+      // Follow the bookmark to the last page; version grouping needs every file
+      const allFiles: BlockchainFile[] = [];
+      let cursor = '';
+      do {
+        const response = await axios.get<{ files: BlockchainFile[]; bookmark: string }>('http://localhost:8080/api/files', {
+          params: cursor ? { cursor } : undefined,
+          headers: {
+            Authorization: `Bearer ${session?.access_token}`,
+            'X-Organization-ID': currentOrg?.id,
+            'X-MSP-ID': currentOrg?.fabric_msp_id
+          }
+        });
+        allFiles.push(...response.data.files);
+        cursor = response.data.bookmark;
+      } while (cursor);
+      setFiles(allFiles);
       setError(null);
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to load files');
@@ -474,6 +481,7 @@ export default function Home() {
                   const content = await readFileContent(selectedFile);
                   
                   const metadata = {
+                    schemaVersion: 1,
                     size: selectedFile.size,
                     type: selectedFile.type || 'application/octet-stream',
                     createdAt: new Date().toISOString(),
@@ -481,7 +489,6 @@ export default function Home() {
                   };
 
                   const payload = {
-                    id: uuidv4(),
                     name: selectedFile.name,
                     content: content,
                     owner: user?.email || "unknown",
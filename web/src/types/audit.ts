@@ -5,4 +5,8 @@ interface AuditLogEntry {
     userId: string;
     orgId: string;
     details: string;
+    txId?: string;
+    sequence?: number;
+    previousHash?: string;
+    hash?: string;
   }
\ No newline at end of file
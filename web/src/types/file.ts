@@ -4,12 +4,44 @@ export interface File {
     hash: string;
     timestamp: string;
     owner: string;
+    ownerMsp?: string;
+    ownerId?: string;
+    pendingTransfer?: {
+      fileId: string;
+      fileName: string;
+      fromMsp: string;
+      fromId: string;
+      toMsp: string;
+      proposedAt: string;
+    };
+    readers?: { mspId: string; attribute?: string; value?: string }[];  // Empty on files registered before read ACLs
+    editors?: { mspId: string; attribute?: string; value?: string }[];  // May register new versions besides the owner
     metadata: string;
     version: number;
     previousID?: string;
     ipfsLocation: string;
-    status: string;               // "PENDING" or "APPROVED"
+    status: string;               // "PENDING", "APPROVED", "REJECTED" or "EXPIRED"
+    approvalDeadline?: string;    // RFC3339, approvals are refused from then on
     requiredOrgs: string[];      // List of required MSP IDs
     currentApprovals: string[];  // List of MSP IDs that have approved
-    endorsementType: string;     // "ANY_ORG", "ALL_ORGS", "SPECIFIC_ORGS"
+    endorsementType: string;     // "ANY_ORG", "ALL_ORGS", "SPECIFIC_ORGS", "CUSTOM", "WORKFLOW"
+    endorsementPolicy?: string;  // e.g. "OutOf(2, 'Org1MSP', 'Org2MSP', 'Org3MSP')", the active stage's policy for workflows
+    workflowId?: string;         // Workflow the file is approved through
+    currentStage?: number;       // 1-based stage awaiting approval
+    privateCollection?: string;  // Set when the metadata is kept in a private data collection
+    retainUntil?: string;        // RFC3339, the file cannot be archived or deleted before then
+    legalHolds?: {               // Active holds block archiving, deleting and unpinning
+      id: string;
+      reason: string;
+      mspId: string;
+      clientId: string;
+      placedAt: string;
+    }[];
+    tombstone?: {                // Set while the file is archived or deleted
+      state: string;             // "ARCHIVED" or "DELETED"
+      reason: string;
+      mspId: string;
+      clientId: string;
+      timestamp: string;
+    };
   }
\ No newline at end of file